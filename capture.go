@@ -0,0 +1,73 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+)
+
+// captureOutput redirects os.Stdout and os.Stderr to the system logger of s
+// when the CaptureOutput option is set and the program is run by the service
+// manager. The standard log package is redirected along with os.Stderr.
+// The returned function restores the original files and waits for the
+// captured output to be logged.
+func captureOutput(s Service, kv KeyValue) (func(), error) {
+	if Interactive() || !kv.bool(optionCaptureOutput, optionCaptureOutputDefault) {
+		return func() {}, nil
+	}
+	l, err := s.SystemLogger(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := redirect(&os.Stdout, l.Info)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := redirect(&os.Stderr, l.Error)
+	if err != nil {
+		stdout()
+		return nil, err
+	}
+	logOutput := log.Writer()
+	log.SetOutput(os.Stderr)
+
+	return func() {
+		log.SetOutput(logOutput)
+		stderr()
+		stdout()
+	}, nil
+}
+
+// redirect replaces *f with a pipe and logs each line written to it.
+func redirect(f **os.File, logLine func(v ...interface{}) error) (func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	orig := *f
+	*f = w
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			logLine(scanner.Text())
+		}
+		// Keep draining so writers do not block on an overlong line.
+		io.Copy(io.Discard, r)
+		r.Close()
+	}()
+
+	return func() {
+		*f = orig
+		w.Close()
+		<-done
+	}, nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	restore, err := redirect(&f, func(v ...interface{}) error {
+		lines = append(lines, fmt.Sprint(v...))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(f, "first")
+	fmt.Fprint(f, "second\nthird")
+	restore()
+
+	want := []string{"first", "second", "third"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("got %q, want %q", lines, want)
+	}
+	if _, err := fmt.Fprintln(f, "after"); err != nil {
+		t.Errorf("original file not restored: %v", err)
+	}
+}
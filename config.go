@@ -0,0 +1,94 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+)
+
+// LoadConfig reads a Config from the JSON file at path, see ParseConfig.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data, json.Unmarshal)
+}
+
+// ParseConfig parses a Config from data decoded by unmarshal, such as
+// json.Unmarshal, or the Unmarshal of a YAML package that decodes mappings
+// to map[string]interface{} such as gopkg.in/yaml.v3.
+//
+// The Config field names match case insensitively. The Option keys are the
+// names documented on KeyValue, their values are converted to the types
+// the options expect: whole numbers to int, lists of strings to []string,
+// mappings of bools to map[string]bool and Triggers to []Trigger. Options
+// taking a func, such as RunWait, can't be set from a file.
+func ParseConfig(data []byte, unmarshal func([]byte, interface{}) error) (*Config, error) {
+	var raw map[string]interface{}
+	if err := unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	// Round trip through JSON to match the fields of Config whatever the
+	// naming convention of the decoder.
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	for k, v := range c.Option {
+		if c.Option[k], err = optionValue(k, v); err != nil {
+			return nil, fmt.Errorf("option %s: %v", k, err)
+		}
+	}
+	return c, nil
+}
+
+// optionValue converts a decoded option value to the type the option expects.
+func optionValue(key string, v interface{}) (interface{}, error) {
+	if key == optionTriggers {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var triggers []Trigger
+		err = json.Unmarshal(b, &triggers)
+		return triggers, err
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v), nil
+		}
+	case []interface{}:
+		strs := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return v, nil
+			}
+			strs[i] = s
+		}
+		return strs, nil
+	case map[string]interface{}:
+		bools := make(map[string]bool, len(v))
+		for k, e := range v {
+			b, ok := e.(bool)
+			if !ok {
+				return v, nil
+			}
+			bools[k] = b
+		}
+		return bools, nil
+	}
+	return v, nil
+}
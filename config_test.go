@@ -0,0 +1,185 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	data := []byte(`{
+		"name": "app",
+		"DisplayName": "App",
+		"arguments": ["-v"],
+		"envVars": {"A": "1"},
+		"option": {
+			"UserService": true,
+			"LimitNOFILE": 1024,
+			"Watch": ["/etc/app.conf"],
+			"KeepAlivePathState": {"/tmp/run": true},
+			"Triggers": [{"Type": "network", "Stop": true}]
+		}
+	}`)
+	c, err := ParseConfig(data, json.Unmarshal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Config{
+		Name:        "app",
+		DisplayName: "App",
+		Arguments:   []string{"-v"},
+		EnvVars:     map[string]string{"A": "1"},
+		Option: KeyValue{
+			"UserService":        true,
+			"LimitNOFILE":        1024,
+			"Watch":              []string{"/etc/app.conf"},
+			"KeepAlivePathState": map[string]bool{"/tmp/run": true},
+			"Triggers":           []Trigger{{Type: "network", Stop: true}},
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("ParseConfig() = %+v, want %+v", c, want)
+	}
+}
+
+func TestUniqueConfig(t *testing.T) {
+	a, err := UniqueConfig("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := UniqueConfig("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name == b.Name {
+		t.Errorf("UniqueConfig() returned %q twice", a.Name)
+	}
+	if !strings.HasPrefix(a.Name, "test_") {
+		t.Errorf("UniqueConfig() = %q, want prefix test_", a.Name)
+	}
+}
+
+func TestRunArgs(t *testing.T) {
+	if !Interactive() {
+		t.Skip("not running interactively")
+	}
+	args := append([]string(nil), os.Args...)
+
+	c := &Config{Arguments: []string{"run"}}
+	if got := c.RunArgs(); !reflect.DeepEqual(got, []string{"run"}) {
+		t.Errorf("without RunArguments RunArgs() = %q, want %q", got, []string{"run"})
+	}
+
+	c.Name = "runargs"
+	c.RunArguments = []string{"run", "-debug"}
+	s, err := New(nil, c)
+	if err != nil {
+		t.Skip(err)
+	}
+	if got := RunArgs(s); !reflect.DeepEqual(got, c.RunArguments) {
+		t.Errorf("RunArgs() = %q, want %q", got, c.RunArguments)
+	}
+	if got := RunArgs(nil); !reflect.DeepEqual(got, os.Args[1:]) {
+		t.Errorf("RunArgs(nil) = %q, want %q", got, os.Args[1:])
+	}
+	if !reflect.DeepEqual(os.Args, args) {
+		t.Errorf("os.Args = %q, want %q", os.Args, args)
+	}
+}
+
+// stuckService is an installed, running Service whose Stop fails.
+type stuckService struct {
+	Service
+	killed, uninstalled bool
+}
+
+func (s *stuckService) Installed() (bool, error) { return !s.uninstalled, nil }
+func (s *stuckService) Status() (Status, error)  { return StatusRunning, nil }
+func (s *stuckService) Stop() error              { return ErrStopTimeout }
+func (s *stuckService) Kill() error              { s.killed = true; return nil }
+func (s *stuckService) Uninstall() error         { s.uninstalled = true; return nil }
+
+func TestForceUninstall(t *testing.T) {
+	s := &stuckService{}
+	if err := ForceUninstall(s); err != nil {
+		t.Fatal(err)
+	}
+	if !s.killed || !s.uninstalled {
+		t.Errorf("ForceUninstall() killed %v, uninstalled %v, want both", s.killed, s.uninstalled)
+	}
+	if err := ForceUninstall(s); err != nil {
+		t.Errorf("ForceUninstall() of uninstalled service error = %v, want nil", err)
+	}
+}
+
+func TestRemoveDirectories(t *testing.T) {
+	root := t.TempDir()
+	userConfigDir = func() (string, error) { return filepath.Join(root, "config"), nil }
+	defer func() { userConfigDir = os.UserConfigDir }()
+
+	empty, nested, full := filepath.Join(root, "empty"), filepath.Join(root, "empty", "nested"), filepath.Join(root, "full")
+	existing := filepath.Join(root, "existing")
+	if err := os.MkdirAll(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{
+		Name:        "test",
+		Directories: []Directory{{Path: empty}, {Path: nested}, {Path: full}, {Path: existing}},
+		Option:      KeyValue{"RemoveDirectories": true},
+	}
+	if err := c.makeDirectories(nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{full, existing} {
+		if err := os.WriteFile(filepath.Join(dir, "state"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirs, err := c.directoriesToRemove()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := removeDirectories(dirs, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(empty); !os.IsNotExist(err) {
+		t.Errorf("empty directory %s was not removed", empty)
+	}
+	if _, err := os.Stat(full); err != nil {
+		t.Errorf("directory %s with a file was removed: %v", full, err)
+	}
+
+	c.Option = KeyValue{"PurgeDirectories": true}
+	if dirs, err = c.directoriesToRemove(); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeDirectories(dirs, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(full); !os.IsNotExist(err) {
+		t.Errorf("directory %s was not purged", full)
+	}
+	if _, err := os.Stat(filepath.Join(existing, "state")); err != nil {
+		t.Errorf("directory %s that Install did not create was purged: %v", existing, err)
+	}
+	if err := removeDirectoryRecord(c.Name); err != nil {
+		t.Fatal(err)
+	}
+	if created, err := readDirectoryRecord(c.Name); err != nil || created != nil {
+		t.Errorf("readDirectoryRecord() after removing the record = %v, %v, want none", created, err)
+	}
+
+	for _, path := range []string{"data", string(filepath.Separator), filepath.Join(string(filepath.Separator), "var")} {
+		c.Directories = []Directory{{Path: path}}
+		if _, err := c.directoriesToRemove(); err == nil {
+			t.Errorf("directoriesToRemove() purging %s returned no error", path)
+		}
+	}
+}
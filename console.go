@@ -5,15 +5,26 @@
 package service
 
 import (
+	"fmt"
 	"log"
 	"os"
 )
 
+// interactiveLogger returns the logger of an interactive run, ConsoleLogger
+// unless the Logger option selects NullLogger.
+func (c *Config) interactiveLogger() Logger {
+	if c.Option.string(optionLogger, "") == optionLoggerNull {
+		return NullLogger
+	}
+	return ConsoleLogger
+}
+
 // ConsoleLogger logs to the std err.
 var ConsoleLogger = consoleLogger{}
 
 type consoleLogger struct {
 	info, warn, err *log.Logger
+	fields          map[string]interface{}
 }
 
 func init() {
@@ -23,26 +34,57 @@ func init() {
 }
 
 func (c consoleLogger) Error(v ...interface{}) error {
-	c.err.Print(v...)
+	c.err.Print(fmt.Sprint(v...) + formatFields(c.fields))
 	return nil
 }
 func (c consoleLogger) Warning(v ...interface{}) error {
-	c.warn.Print(v...)
+	c.warn.Print(fmt.Sprint(v...) + formatFields(c.fields))
 	return nil
 }
 func (c consoleLogger) Info(v ...interface{}) error {
-	c.info.Print(v...)
+	c.info.Print(fmt.Sprint(v...) + formatFields(c.fields))
 	return nil
 }
 func (c consoleLogger) Errorf(format string, a ...interface{}) error {
-	c.err.Printf(format, a...)
+	c.err.Print(fmt.Sprintf(format, a...) + formatFields(c.fields))
 	return nil
 }
 func (c consoleLogger) Warningf(format string, a ...interface{}) error {
-	c.warn.Printf(format, a...)
+	c.warn.Print(fmt.Sprintf(format, a...) + formatFields(c.fields))
 	return nil
 }
 func (c consoleLogger) Infof(format string, a ...interface{}) error {
-	c.info.Printf(format, a...)
+	c.info.Print(fmt.Sprintf(format, a...) + formatFields(c.fields))
+	return nil
+}
+func (c consoleLogger) With(fields map[string]interface{}) Logger {
+	c.fields = withFields(c.fields, fields)
+	return c
+}
+
+// NullLogger discards everything logged to it and returns nil errors.
+var NullLogger = nullLogger{}
+
+type nullLogger struct{}
+
+func (nullLogger) Error(v ...interface{}) error {
+	return nil
+}
+func (nullLogger) Warning(v ...interface{}) error {
 	return nil
 }
+func (nullLogger) Info(v ...interface{}) error {
+	return nil
+}
+func (nullLogger) Errorf(format string, a ...interface{}) error {
+	return nil
+}
+func (nullLogger) Warningf(format string, a ...interface{}) error {
+	return nil
+}
+func (nullLogger) Infof(format string, a ...interface{}) error {
+	return nil
+}
+func (n nullLogger) With(fields map[string]interface{}) Logger {
+	return n
+}
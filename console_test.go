@@ -0,0 +1,41 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSendError(t *testing.T) {
+	err := errors.New("write failed")
+
+	// Neither a nil nor an unread channel may block or panic.
+	sendError(nil, err)
+	sendError(make(chan error), err)
+
+	errs := make(chan error, 1)
+	sendError(errs, nil)
+	sendError(errs, err)
+	select {
+	case got := <-errs:
+		if got != err {
+			t.Errorf("got %v, want %v", got, err)
+		}
+	default:
+		t.Error("error not sent on a buffered channel")
+	}
+}
+
+func TestNullLogger(t *testing.T) {
+	c := &Config{Option: KeyValue{"Logger": "null"}}
+	l := c.interactiveLogger()
+	if l != NullLogger {
+		t.Fatalf("interactiveLogger() = %T, want NullLogger", l)
+	}
+	if err := l.With(map[string]interface{}{"k": "v"}).Errorf("%d", 1); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package service
+
+import "os"
+
+// inContainer checks the container environment variable, the systems have
+// no common container engine with a marker of its own.
+func inContainer() bool {
+	return os.Getenv("container") != ""
+}
@@ -0,0 +1,202 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileRotation configures when a file logger rotates its file and which
+// rotated files it keeps.
+type FileRotation struct {
+	MaxSize    int64         // Rotate before the file grows past this many bytes, 10 MB if zero.
+	Interval   time.Duration // Also rotate once the file is this old, never if zero.
+	MaxBackups int           // Rotated files to keep, all if zero.
+	MaxAge     time.Duration // Remove rotated files older than this, never if zero.
+}
+
+// NewFileLogger returns a Logger that appends to the file at path, creating
+// it and its directory if needed. When the file is rotated it is renamed
+// with the time appended to its name. If errs is non-nil errors will be sent
+// on errs as well as returned from Logger's functions.
+func NewFileLogger(path string, rotation FileRotation, errs chan<- error) (Logger, error) {
+	if rotation.MaxSize <= 0 {
+		rotation.MaxSize = 10 << 20
+	}
+	f := &rotatingFile{path: path, rotation: rotation}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return fileLogger{f, errs, nil}, nil
+}
+
+// fileLogger returns the file logger set by the LogFile options.
+func (c *Config) fileLogger(errs chan<- error) (Logger, error) {
+	maxAge, _ := time.ParseDuration(c.Option.string(optionLogFileMaxAge, ""))
+	interval, _ := time.ParseDuration(c.Option.string(optionLogFileRotateInterval, ""))
+	return NewFileLogger(c.Option.string(optionLogFile, ""), FileRotation{
+		MaxSize:    int64(c.Option.int(optionLogFileMaxSize, 10)) << 20,
+		Interval:   interval,
+		MaxBackups: c.Option.int(optionLogFileMaxBackups, 0),
+		MaxAge:     maxAge,
+	}, errs)
+}
+
+// backupTimeFormat is appended to the name of a rotated file.
+const backupTimeFormat = "20060102T150405.000"
+
+// rotatingFile is the file shared by a fileLogger and those made by With.
+type rotatingFile struct {
+	path     string
+	rotation FileRotation
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size, r.opened = f, fi.Size(), time.Now()
+	if r.size > 0 {
+		// The file was started when the newest backup was rotated out. The
+		// modification time is only the last write.
+		if backups := r.backups(); len(backups) > 0 {
+			last := backups[len(backups)-1]
+			if t, err := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(last, r.path+"."), time.Local); err == nil {
+				r.opened = t
+			}
+		}
+	}
+	return nil
+}
+
+// backups returns the rotated files, oldest first.
+func (r *rotatingFile) backups() []string {
+	matches, _ := filepath.Glob(r.path + ".*")
+	var backups []string
+	for _, m := range matches {
+		if _, err := time.Parse(backupTimeFormat, strings.TrimPrefix(m, r.path+".")); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	// The time suffix sorts oldest first.
+	sort.Strings(backups)
+	return backups
+}
+
+func (r *rotatingFile) write(line string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		// Closed by a rotation that couldn't reopen it.
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	var rotateErr error
+	due := r.rotation.Interval > 0 && time.Since(r.opened) >= r.rotation.Interval
+	if r.size > 0 && (r.size+int64(len(line)) > r.rotation.MaxSize || due) {
+		if rotateErr = r.rotate(); r.f == nil {
+			return rotateErr
+		}
+	}
+	n, err := r.f.WriteString(line)
+	r.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// rotate renames the file, opens a new one and removes the rotated files
+// that are no longer kept.
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	r.f = nil
+	backup := r.path + "." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(r.path, backup); err != nil {
+		// Keep appending to the file rather than leave the logger closed.
+		if err := r.open(); err != nil {
+			return err
+		}
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	backups := r.backups()
+	for i, b := range backups {
+		expired := false
+		if r.rotation.MaxAge > 0 {
+			if fi, err := os.Stat(b); err == nil && time.Since(fi.ModTime()) > r.rotation.MaxAge {
+				expired = true
+			}
+		}
+		if expired || (r.rotation.MaxBackups > 0 && i < len(backups)-r.rotation.MaxBackups) {
+			os.Remove(b)
+		}
+	}
+	return nil
+}
+
+type fileLogger struct {
+	f      *rotatingFile
+	errs   chan<- error
+	fields map[string]interface{}
+}
+
+func (l fileLogger) write(level, msg string) error {
+	line := time.Now().Format(time.RFC3339) + " " + level + ": " + msg + formatFields(l.fields)
+	if !strings.HasSuffix(line, "\n") {
+		line += "\n"
+	}
+	err := l.f.write(line)
+	sendError(l.errs, err)
+	return err
+}
+
+func (l fileLogger) Error(v ...interface{}) error {
+	return l.write("E", fmt.Sprint(v...))
+}
+func (l fileLogger) Warning(v ...interface{}) error {
+	return l.write("W", fmt.Sprint(v...))
+}
+func (l fileLogger) Info(v ...interface{}) error {
+	return l.write("I", fmt.Sprint(v...))
+}
+func (l fileLogger) Errorf(format string, a ...interface{}) error {
+	return l.write("E", fmt.Sprintf(format, a...))
+}
+func (l fileLogger) Warningf(format string, a ...interface{}) error {
+	return l.write("W", fmt.Sprintf(format, a...))
+}
+func (l fileLogger) Infof(format string, a ...interface{}) error {
+	return l.write("I", fmt.Sprintf(format, a...))
+}
+func (l fileLogger) With(fields map[string]interface{}) Logger {
+	l.fields = withFields(l.fields, fields)
+	return l
+}
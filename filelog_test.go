@@ -0,0 +1,71 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	l, err := NewFileLogger(path, FileRotation{MaxSize: 100, MaxBackups: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.With(map[string]interface{}{"id": 7}).Info("first")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := string(b); !strings.HasSuffix(line, " I: first id=7\n") {
+		t.Errorf("got line %q", line)
+	}
+
+	for i := 0; i < 3; i++ {
+		// Rotated files are named by the millisecond.
+		time.Sleep(2 * time.Millisecond)
+		if err := l.Error(strings.Repeat("x", 60)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("got backups %q, want 1", backups)
+	}
+}
+
+func TestFileLoggerInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The file was started by a rotation two hours ago, and written since.
+	started := time.Now().Add(-2 * time.Hour)
+	if err := os.WriteFile(path+"."+started.Format(backupTimeFormat), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := NewFileLogger(path, FileRotation{Interval: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Info("new"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "old") {
+		t.Errorf("file started %v ago not rotated after an interval of %v", time.Since(started), time.Hour)
+	}
+}
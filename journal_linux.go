@@ -0,0 +1,71 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/syslog"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalSend sends msg with the fields to the journal using its native
+// protocol. It reports false if the journal isn't running so the caller can
+// fall back to syslog.
+func journalSend(identifier string, p syslog.Priority, msg string, fields map[string]interface{}) (bool, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		return false, nil
+	}
+	defer conn.Close()
+
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", msg)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(int(p)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", identifier)
+	for k, v := range fields {
+		if name := journalFieldName(k); name != "" {
+			writeJournalField(&b, name, fmt.Sprint(v))
+		}
+	}
+	_, err = conn.Write(b.Bytes())
+	return true, err
+}
+
+// writeJournalField writes a field in the native journal format. Values
+// with a newline are written with their length instead of a separator.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(b, "%s=%s\n", name, value)
+		return
+	}
+	b.WriteString(name)
+	b.WriteByte('\n')
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journalFieldName returns key as a journal field name, which has only
+// upper case letters, digits and underscores and starts with a letter.
+// It returns an empty string if nothing is left of key.
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	return strings.TrimLeft(name, "_0123456789")
+}
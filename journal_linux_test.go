@@ -0,0 +1,37 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJournalFieldName(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"request_id", "REQUEST_ID"},
+		{"user.name", "USER_NAME"},
+		{"_private", "PRIVATE"},
+		{"2fa", "FA"},
+		{"__", ""},
+	}
+	for _, tt := range tests {
+		if got := journalFieldName(tt.key); got != tt.want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestWriteJournalField(t *testing.T) {
+	var b bytes.Buffer
+	writeJournalField(&b, "A", "one")
+	writeJournalField(&b, "B", "two\nlines")
+	want := "A=one\nB\n\x09\x00\x00\x00\x00\x00\x00\x00two\nlines\n"
+	if got := b.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
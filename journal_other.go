@@ -0,0 +1,15 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || solaris || aix || freebsd
+// +build darwin solaris aix freebsd
+
+package service
+
+import "log/syslog"
+
+// journalSend reports false, there is no journal on this system.
+func journalSend(identifier string, p syslog.Priority, msg string, fields map[string]interface{}) (bool, error) {
+	return false, nil
+}
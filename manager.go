@@ -0,0 +1,161 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import "errors"
+
+// Manager controls services by name over a single connection to the service
+// manager. On Windows the connection to the service control manager is kept
+// open until Close is called, on other systems each call runs the service
+// manager command like the methods of Service do.
+//
+// The one-shot methods of Service remain the simplest way to control a single
+// service, a Manager is for tools that perform many operations.
+type Manager struct {
+	conn managerConn
+}
+
+// managerConn is the platform specific connection of a Manager.
+type managerConn interface {
+	status(name string) (Status, error)
+	start(name string) error
+	stop(name string) error
+	restart(name string) error
+	list() ([]string, error)
+	close() error
+}
+
+// serviceLister is implemented by systems that can list the names of the
+// installed services.
+type serviceLister interface {
+	list() ([]string, error)
+}
+
+// managerConnector is implemented by systems that keep a connection to the
+// service manager open for a Manager.
+type managerConnector interface {
+	connect() (managerConn, error)
+}
+
+// NewManager connects to the service manager of the chosen system.
+// Close must be called to release the connection.
+func NewManager() (*Manager, error) {
+	if system == nil {
+		return nil, ErrNoServiceSystemDetected
+	}
+	if c, ok := system.(managerConnector); ok {
+		conn, err := c.connect()
+		if err != nil {
+			return nil, err
+		}
+		return &Manager{conn: conn}, nil
+	}
+	return &Manager{conn: serviceConn{}}, nil
+}
+
+// Status returns the status of the named service.
+func (m *Manager) Status(name string) (Status, error) {
+	return m.conn.status(name)
+}
+
+// Start starts the named service.
+func (m *Manager) Start(name string) error {
+	return m.conn.start(name)
+}
+
+// Stop stops the named service.
+func (m *Manager) Stop(name string) error {
+	return m.conn.stop(name)
+}
+
+// Restart stops then starts the named service.
+func (m *Manager) Restart(name string) error {
+	return m.conn.restart(name)
+}
+
+// List returns the names of the installed services. Implemented on Windows
+// and Linux, it returns ErrNotSupported on other systems. On Linux other than
+// systemd these are the scripts in the init directory.
+func (m *Manager) List() ([]string, error) {
+	return m.conn.list()
+}
+
+// Get returns the named installed service, or ErrNotInstalled. Where
+// InstalledConfig is supported the Service has the installed configuration,
+// otherwise only its name.
+func (m *Manager) Get(name string) (Service, error) {
+	c, err := InstalledConfig(name)
+	if errors.Is(err, ErrNotSupported) {
+		c = &Config{Name: name}
+	} else if err != nil {
+		return nil, err
+	}
+	s, err := system.New(nil, c)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := s.Installed(); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrNotInstalled
+	}
+	return s, nil
+}
+
+// Close releases the connection to the service manager.
+func (m *Manager) Close() error {
+	return m.conn.close()
+}
+
+// serviceConn controls each service through a Service of the chosen system,
+// for systems without a connection to keep open.
+type serviceConn struct{}
+
+func (serviceConn) service(name string) (Service, error) {
+	return system.New(nil, &Config{Name: name})
+}
+
+func (c serviceConn) status(name string) (Status, error) {
+	s, err := c.service(name)
+	if err != nil {
+		return StatusUnknown, err
+	}
+	return s.Status()
+}
+
+func (c serviceConn) start(name string) error {
+	s, err := c.service(name)
+	if err != nil {
+		return err
+	}
+	return s.Start()
+}
+
+func (c serviceConn) stop(name string) error {
+	s, err := c.service(name)
+	if err != nil {
+		return err
+	}
+	return s.Stop()
+}
+
+func (c serviceConn) restart(name string) error {
+	s, err := c.service(name)
+	if err != nil {
+		return err
+	}
+	return s.Restart()
+}
+
+func (serviceConn) list() ([]string, error) {
+	if l, ok := system.(serviceLister); ok {
+		return l.list()
+	}
+	return nil, ErrNotSupported
+}
+
+func (serviceConn) close() error {
+	return nil
+}
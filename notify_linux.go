@@ -0,0 +1,59 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sdNotify sends state, such as "READY=1", to the service manager on the
+// socket of NOTIFY_SOCKET, as sd_notify does. It does nothing when the
+// variable is not set, such as when the unit isn't a notify unit.
+func sdNotify(state string) error {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return nil
+	}
+	if addr[0] == '@' {
+		// Abstract socket.
+		addr = "\x00" + addr[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// sdNotifyReloading tells the service manager a reload started. systemd
+// needs the time of CLOCK_MONOTONIC to tell it from an earlier reload.
+func sdNotifyReloading() error {
+	var ts unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts); err != nil {
+		return err
+	}
+	return sdNotify("RELOADING=1\nMONOTONIC_USEC=" + strconv.FormatInt(ts.Nano()/1000, 10))
+}
+
+// sdNotifyReloadFailed tells the service manager a reload ended with err:
+// the service is ready again so the reload job completes, with err as its
+// status and, for a system call error, its errno.
+func sdNotifyReloadFailed(err error) error {
+	state := "READY=1\nSTATUS=Reload failed: " + strings.ReplaceAll(err.Error(), "\n", " ")
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		state += "\nERRNO=" + strconv.Itoa(int(errno))
+	}
+	return sdNotify(state)
+}
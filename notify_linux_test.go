@@ -0,0 +1,54 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"testing"
+)
+
+func TestSdNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := sdNotify("READY=1"); err != nil {
+		t.Errorf("sdNotify() without NOTIFY_SOCKET error = %v, want nil", err)
+	}
+
+	addr := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", addr)
+
+	buf := make([]byte, 256)
+	if err := sdNotify("READY=1"); err != nil {
+		t.Fatal(err)
+	}
+	n, err := conn.Read(buf)
+	if err != nil || string(buf[:n]) != "READY=1" {
+		t.Errorf("received %q, %v, want READY=1", buf[:n], err)
+	}
+
+	if err := sdNotifyReloading(); err != nil {
+		t.Fatal(err)
+	}
+	n, err = conn.Read(buf)
+	if err != nil || !regexp.MustCompile(`^RELOADING=1\nMONOTONIC_USEC=[1-9][0-9]*$`).Match(buf[:n]) {
+		t.Errorf("received %q, %v, want RELOADING=1 and MONOTONIC_USEC", buf[:n], err)
+	}
+
+	if err := sdNotifyReloadFailed(fmt.Errorf("start: %w", syscall.EACCES)); err != nil {
+		t.Fatal(err)
+	}
+	n, err = conn.Read(buf)
+	if want := "READY=1\nSTATUS=Reload failed: start: permission denied\nERRNO=13"; err != nil || string(buf[:n]) != want {
+		t.Errorf("received %q, %v, want %q", buf[:n], err, want)
+	}
+}
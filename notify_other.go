@@ -0,0 +1,23 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build darwin || solaris || aix || freebsd
+// +build darwin solaris aix freebsd
+
+package service
+
+// sdNotify does nothing, there is no systemd on this system.
+func sdNotify(state string) error {
+	return nil
+}
+
+// sdNotifyReloading does nothing, there is no systemd on this system.
+func sdNotifyReloading() error {
+	return nil
+}
+
+// sdNotifyReloadFailed does nothing, there is no systemd on this system.
+func sdNotifyReloadFailed(err error) error {
+	return nil
+}
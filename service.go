@@ -62,8 +62,17 @@
 package service // import "github.com/patchsimple/service"
 
 import (
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 const (
@@ -77,6 +86,12 @@ const (
 	optionSessionCreateDefault          = false
 	optionLimitLoadToSessionType        = "LimitLoadToSessionType"
 	optionLimitLoadToSessionTypeDefault = "Aqua"
+	optionGroupName                     = "GroupName"
+	optionKeepAlivePathState            = "KeepAlivePathState"
+	optionKeepAliveOtherJobEnabled      = "KeepAliveOtherJobEnabled"
+	optionStartOnMount                  = "StartOnMount"
+	optionStartOnMountDefault           = false
+	optionStandardInPath                = "StandardInPath"
 	optionLogOutput                     = "LogOutput"
 	optionLogOutputDefault              = false
 	optionPrefix                        = "Prefix"
@@ -89,7 +104,73 @@ const (
 	optionLimitNOFILEDefault = -1 // -1 = don't set in configuration
 	optionRestart            = "Restart"
 
-	optionSuccessExitStatus = "SuccessExitStatus"
+	optionSuccessExitStatus        = "SuccessExitStatus"
+	optionRestartPreventExitStatus = "RestartPreventExitStatus"
+	optionStopTimeout              = "StopTimeout"
+	optionTriggers                 = "Triggers"
+
+	optionCaptureOutput        = "CaptureOutput"
+	optionCaptureOutputDefault = false
+	optionWatch                = "Watch"
+	optionRestartOnReload      = "RestartOnReload"
+	optionReapChildren         = "ReapChildren"
+	optionNotifyReload         = "NotifyReload"
+
+	optionLogFile               = "LogFile"
+	optionLogFileMaxSize        = "LogFileMaxSize"
+	optionLogFileMaxBackups     = "LogFileMaxBackups"
+	optionLogFileMaxAge         = "LogFileMaxAge"
+	optionLogFileRotateInterval = "LogFileRotateInterval"
+
+	optionLogger     = "Logger"
+	optionLoggerNull = "null"
+
+	optionOverwrite        = "Overwrite"
+	optionOverwriteDefault = false
+	optionDropIn           = "DropIn"
+	optionDropInDefault    = false
+
+	optionDaemonReload        = "DaemonReload"
+	optionDaemonReloadDefault = true
+
+	optionSlice       = "Slice"
+	optionSliceConfig = "SliceConfig"
+
+	optionExecStartPre  = "ExecStartPre"
+	optionExecStartPost = "ExecStartPost"
+
+	optionTimeoutStartSec = "TimeoutStartSec"
+	optionTimeoutStopSec  = "TimeoutStopSec"
+
+	optionKillMode   = "KillMode"
+	optionKillSignal = "KillSignal"
+
+	optionLoadCredential = "LoadCredential"
+	optionSetCredential  = "SetCredential"
+
+	optionPartOf     = "PartOf"
+	optionTarget     = "Target"
+	optionWantedBy   = "WantedBy"
+	optionRequiredBy = "RequiredBy"
+	optionConditions = "Conditions"
+
+	optionDefaultDependencies        = "DefaultDependencies"
+	optionDefaultDependenciesDefault = true
+
+	optionNice                  = "Nice"
+	optionIOSchedulingClass     = "IOSchedulingClass"
+	optionIOSchedulingPriority  = "IOSchedulingPriority"
+	optionCPUSchedulingPolicy   = "CPUSchedulingPolicy"
+	optionCPUSchedulingPriority = "CPUSchedulingPriority"
+
+	optionRuntimeDirectory     = "RuntimeDirectory"
+	optionRuntimeDirectoryMode = "RuntimeDirectoryMode"
+	optionStateDirectory       = "StateDirectory"
+	optionStateDirectoryMode   = "StateDirectoryMode"
+	optionLogsDirectory        = "LogsDirectory"
+	optionLogsDirectoryMode    = "LogsDirectoryMode"
+	optionRemoveDirectories    = "RemoveDirectories"
+	optionPurgeDirectories     = "PurgeDirectories"
 
 	optionSystemdScript = "SystemdScript"
 	optionSysvScript    = "SysvScript"
@@ -101,6 +182,16 @@ const (
 	optionLogDirectory = "LogDirectory"
 )
 
+// StartType option values. On Windows these map to the service start type.
+// On the other systems automatic enables the service to start at boot while
+// manual and disabled install it without enabling it.
+const (
+	StartType             = "StartType"
+	ServiceStartManual    = "manual"
+	ServiceStartDisabled  = "disabled"
+	ServiceStartAutomatic = "automatic"
+)
+
 // Status represents service status as an byte value
 type Status byte
 
@@ -109,8 +200,87 @@ const (
 	StatusUnknown Status = iota // Status is unable to be determined due to an error or it was not installed.
 	StatusRunning
 	StatusStopped
+	StatusFailed // Installed but stopped after failing, such as a crash or a non-zero exit code.
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusRunning:
+		return "running"
+	case StatusStopped:
+		return "stopped"
+	case StatusFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText marshals the status as its name, such as "running".
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Trigger types for the Type field of a Trigger.
+const (
+	// TriggerCustom fires on an event of the ETW provider given by Subtype.
+	TriggerCustom = "custom"
+	// TriggerDomainJoin fires when the computer joins a domain, or with
+	// Subtype TriggerSubtypeDomainLeave when it leaves one.
+	TriggerDomainJoin = "domainJoin"
+	// TriggerFirewallPort fires when a firewall port is opened, or with
+	// Subtype TriggerSubtypeFirewallPortClose when it is closed. Data is the
+	// port and the protocol, optionally followed by the path of the
+	// executable and the name of the service listening on the port.
+	TriggerFirewallPort = "firewallPort"
+)
+
+// Subtypes of the domain join and firewall port triggers. The first of each
+// is the default when Subtype is empty.
+const (
+	TriggerSubtypeDomainJoin        = "{1ce20aba-9851-4421-9430-1ddeb766e809}"
+	TriggerSubtypeDomainLeave       = "{ddaf516e-58c2-4866-9574-c3b615d42ea1}"
+	TriggerSubtypeFirewallPortOpen  = "{b7569e07-8421-4ee0-ad10-86915afdad09}"
+	TriggerSubtypeFirewallPortClose = "{a144ed38-8e12-4de4-9d96-e64740b1a524}"
 )
 
+// Trigger is a Windows service trigger, an event that starts or stops the
+// service so it can stay stopped until its signal occurs. Triggers are set
+// with the Triggers option as a []Trigger and are ignored on other systems.
+//
+// A custom trigger starting the service on any event of an ETW provider:
+//
+//	service.Trigger{
+//		Type:    service.TriggerCustom,
+//		Subtype: "{22fb2cd6-0e7b-422b-a0c7-2fad1fd0e716}", // Microsoft-Windows-Kernel-Process
+//	}
+//
+// Triggers starting the service when TCP port 8080 is opened and stopping it
+// when the computer leaves its domain:
+//
+//	[]service.Trigger{
+//		{Type: service.TriggerFirewallPort, Data: []string{"8080", "TCP"}},
+//		{Type: service.TriggerDomainJoin, Subtype: service.TriggerSubtypeDomainLeave, Stop: true},
+//	}
+type Trigger struct {
+	Type string // Trigger type, such as TriggerCustom.
+	Stop bool   // Stop the service on the event, the default is to start it.
+
+	// Subtype is the GUID qualifying the trigger, for a custom trigger the
+	// GUID of the ETW provider, or one of the TriggerSubtype constants.
+	Subtype string
+
+	// Optional filters of the events of a custom trigger. Level is the
+	// maximum event level, KeywordsAny and KeywordsAll are keyword masks
+	// the event must match any or all of. Zero values don't filter.
+	Level       uint8
+	KeywordsAny uint64
+	KeywordsAll uint64
+
+	// Data are string data items of the trigger.
+	Data []string
+}
+
 // Config provides the setup for a Service. The Name field is required.
 type Config struct {
 	Name        string   // Required name of the service. No spaces suggested.
@@ -119,8 +289,15 @@ type Config struct {
 	UserName    string   // Run as username.
 	Arguments   []string // Run with arguments.
 
+	// Optional arguments used instead of Arguments when the program is run
+	// interactively, such as extra flags while debugging locally. Run leaves
+	// os.Args alone; Start reads them with the RunArgs func. Arguments
+	// remain the installed command line.
+	RunArguments []string
+
 	// Optional field to specify the executable for service.
-	// If empty the current executable is used.
+	// If empty the current executable is used. A relative path is resolved
+	// against WorkingDirectory if set, otherwise against the current directory.
 	Executable string
 
 	// Array of service dependencies.
@@ -129,10 +306,26 @@ type Config struct {
 	//     element of the string array, such as
 	//     "After=network.target syslog.target"
 	//     "Requires=syslog.target"
+	//     "BindsTo=partner.service"
 	//     Note, such lines will be directly appended into the [Unit] of
 	//     the generated service config file, will not check their correctness.
+	//     BindsTo= stops the service when the partner stops, Requisite= fails
+	//     its start when the partner is not already running.
+	//  2. Support linux-openrc dependencies, the systemd style lines above are
+	//     translated into the depend() function of the init script:
+	//     "Requires=" and "BindsTo=" become need, "Wants=" becomes use,
+	//     "After=" becomes after and "Before=" becomes before. "Requisite="
+	//     has no OpenRC equivalent and is left out. Native lines such as
+	//     "need net" are written as is.
 	Dependencies []string
 
+	// Directories the service needs, such as for logs or data. Install
+	// creates them if they do not exist and sets their mode and owner.
+	// Uninstall leaves them in place, unless the RemoveDirectories or
+	// PurgeDirectories option is set, then it removes the ones Install
+	// created.
+	Directories []Directory
+
 	// The following fields are not supported on Windows.
 	WorkingDirectory string // Initial working directory.
 	ChRoot           string
@@ -155,8 +348,76 @@ var (
 	ErrNoServiceSystemDetected = errors.New("No service system detected.")
 	// ErrNotInstalled is returned when the service is not installed.
 	ErrNotInstalled = errors.New("the service is not installed")
+	// ErrNotRunning is returned when the service process is not running.
+	ErrNotRunning = errors.New("the service is not running")
+	// ErrInsufficientPrivileges is returned when Install is not allowed to
+	// change the system service configuration.
+	ErrInsufficientPrivileges = errors.New("insufficient privileges")
+	// ErrNotSupported is returned when the service system does not support
+	// the operation.
+	ErrNotSupported = errors.New("not supported by the service system")
+	// ErrStopTimeout is returned when the service is still running after the
+	// stop timeout. The stop may be retried, or the process killed.
+	ErrStopTimeout = errors.New("the service did not stop in time")
+	// ErrHasDependents is returned by Uninstall when other installed services
+	// depend on the service. The error message lists them.
+	ErrHasDependents = errors.New("other services depend on the service")
+	// ErrDisplayNameInUse is returned by Install on Windows when another
+	// service has the same display name.
+	ErrDisplayNameInUse = errors.New("the display name is used by another service")
+	// ErrHealthTimeout is returned by WaitHealthy when the program is not
+	// healthy in time. The error message has the last error of Healthy.
+	ErrHealthTimeout = errors.New("the service was not healthy in time")
+	// ErrStartTimeout is returned by WaitRunning when the service is not
+	// running after the timeout.
+	ErrStartTimeout = errors.New("the service did not start in time")
+	// ErrInvalidName is returned by Install and Config.Validate when
+	// Config.Name breaks the rules of the service system. The error message
+	// tells which rule.
+	ErrInvalidName = errors.New("invalid service name")
 )
 
+// privilegeError wraps a permission error so it matches both
+// ErrInsufficientPrivileges and the original error, and tells the user how
+// to fix it. Other errors are returned unchanged.
+func privilegeError(err error) error {
+	if err == nil || !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	return fmt.Errorf("%w, %s: %w", ErrInsufficientPrivileges, privilegeHint, err)
+}
+
+// CanManage reports whether the current process has the rights to install,
+// uninstall, start and stop system services, so a program can ask the user
+// to elevate before trying. On Windows it connects to the service control
+// manager with the right to create services, elsewhere it checks for root or,
+// on Linux, the CAP_SYS_ADMIN capability.
+func CanManage() (bool, error) {
+	return canManage()
+}
+
+// RelaunchElevated runs the current command line again with the rights to
+// manage services when the process doesn't have them, so a program started
+// by a user can Install its own service. On Windows the user confirms a UAC
+// prompt and RelaunchElevated waits for the elevated process to exit.
+//
+// It reports whether it relaunched the command, then the caller should exit
+// as the elevated process did the work. The error is set if the elevated
+// process failed. It returns false and a nil error when the process already
+// has the rights, and ErrNotSupported on other systems.
+//
+//	if relaunched, err := service.RelaunchElevated(); relaunched || err != nil {
+//		return err
+//	}
+//	err = s.Install()
+func RelaunchElevated() (bool, error) {
+	ok, err := CanManage()
+	if err != nil || ok {
+		return false, err
+	}
+	return relaunchElevated()
+}
+
 // New creates a new service based on a service interface and configuration.
 func New(i Interface, c *Config) (Service, error) {
 	if len(c.Name) == 0 {
@@ -168,6 +429,304 @@ func New(i Interface, c *Config) (Service, error) {
 	return system.New(i, c)
 }
 
+// RunArgs returns the arguments for how the program is being run. When running
+// interactively and RunArguments is set it returns RunArguments, otherwise
+// it returns Arguments, the arguments the service is installed with.
+func (c *Config) RunArgs() []string {
+	if c.RunArguments != nil && Interactive() {
+		return c.RunArguments
+	}
+	return c.Arguments
+}
+
+// runArger is implemented by services that know their Config.
+type runArger interface {
+	RunArgs() []string
+}
+
+// RunArgs returns the arguments s is run with, for Start to parse instead of
+// os.Args[1:]: the RunArguments of its Config when running interactively,
+// otherwise its Arguments. Falls back to os.Args[1:] for a Service that
+// doesn't know its Config.
+func RunArgs(s Service) []string {
+	r, ok := s.(runArger)
+	if !ok {
+		return os.Args[1:]
+	}
+	return r.RunArgs()
+}
+
+// startAtBoot reports if the StartType option has the service enabled to
+// start at boot, which is the default.
+func (c *Config) startAtBoot() bool {
+	return c.Option.string(StartType, ServiceStartAutomatic) == ServiceStartAutomatic
+}
+
+// Validate checks Config.Name against the rules of the chosen system, which
+// Install checks too: on every system the name can't be "." or "..", or
+// contain a slash or a control character. Windows names can't contain a
+// backslash and have at most 256 characters, mind that Windows compares
+// them without case. systemd unit names only have ASCII letters, digits and
+// ":-_.@\", and at most 255 characters with the ".service" suffix. The
+// error matches ErrInvalidName.
+func (c *Config) Validate() error {
+	if len(c.Name) == 0 {
+		return ErrNameFieldRequired
+	}
+	if system == nil {
+		return ErrNoServiceSystemDetected
+	}
+	s, err := system.New(nil, c)
+	if err != nil {
+		return err
+	}
+	return validateName(s, c.Name)
+}
+
+// nameValidator is implemented by services whose system has rules for the
+// service name beyond the ones of validateName.
+type nameValidator interface {
+	validateName() error
+}
+
+// validateName checks name against the rules of every system, then the ones
+// of the system of s.
+func validateName(s Service, name string) error {
+	if name == "." || name == ".." {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	for _, r := range name {
+		if r == '/' || r < ' ' || r == 0x7f {
+			return fmt.Errorf("%w %q: contains %q", ErrInvalidName, name, r)
+		}
+	}
+	if v, ok := s.(nameValidator); ok {
+		return v.validateName()
+	}
+	return nil
+}
+
+// directoriesToRemove returns the directories Uninstall removes, the
+// Directories and the directories of the RuntimeDirectory, StateDirectory
+// and LogsDirectory options that Install created, innermost first. None
+// without the RemoveDirectories or PurgeDirectories option.
+// PurgeDirectories is refused for a path that isn't absolute or is a root or
+// top level directory, such as a Directory of "/var" by mistake.
+func (c *Config) directoriesToRemove() ([]string, error) {
+	purge := c.Option.bool(optionPurgeDirectories, false)
+	if !purge && !c.Option.bool(optionRemoveDirectories, false) {
+		return nil, nil
+	}
+	created, err := readDirectoryRecord(c.Name)
+	if err != nil {
+		return nil, err
+	}
+	dirs := append(c.Directories[:len(c.Directories):len(c.Directories)], c.optionDirectories()...)
+	paths := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		path := filepath.Clean(d.Path)
+		parent := filepath.Dir(path)
+		if purge && (!filepath.IsAbs(path) || parent == path || filepath.Dir(parent) == parent) {
+			return nil, fmt.Errorf("refusing to purge directory %s", d.Path)
+		}
+		if created[path] {
+			paths = append(paths, path)
+		}
+	}
+	// Longest first, so nested directories go before their parents.
+	sort.SliceStable(paths, func(i, j int) bool { return len(paths[i]) > len(paths[j]) })
+	return paths, nil
+}
+
+// userConfigDir is where the directory records are kept, a variable for the
+// tests.
+var userConfigDir = os.UserConfigDir
+
+// directoryRecord returns the path of the file recording the directories
+// Install created for the service name.
+func directoryRecord(name string) (string, error) {
+	dir, err := userConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "service", name+".directories"), nil
+}
+
+// readDirectoryRecord returns the directories Install created for the
+// service name, none if it has no record.
+func readDirectoryRecord(name string) (map[string]bool, error) {
+	path, err := directoryRecord(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	created := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			created[line] = true
+		}
+	}
+	return created, nil
+}
+
+// recordDirectories adds paths to the directories Install created for the
+// service name.
+func recordDirectories(name string, paths []string) error {
+	created, err := readDirectoryRecord(name)
+	if err != nil {
+		return err
+	}
+	all := make(map[string]bool, len(created)+len(paths))
+	for path := range created {
+		all[path] = true
+	}
+	for _, path := range paths {
+		all[path] = true
+	}
+	lines := make([]string, 0, len(all))
+	for path := range all {
+		lines = append(lines, path)
+	}
+	sort.Strings(lines)
+	path, err := directoryRecord(name)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// removeDirectoryRecord removes the record of the directories Install
+// created for the service name, once Uninstall removed them.
+func removeDirectoryRecord(name string) error {
+	path, err := directoryRecord(name)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// removeDirectories removes the directories at paths, with purge with
+// their content, otherwise only the empty ones.
+func removeDirectories(paths []string, purge bool) error {
+	for _, path := range paths {
+		if purge {
+			if err := os.RemoveAll(path); err != nil {
+				return privilegeError(err)
+			}
+			continue
+		}
+		if entries, err := os.ReadDir(path); err != nil || len(entries) > 0 {
+			// Missing, or holding state to keep.
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return privilegeError(err)
+		}
+	}
+	return nil
+}
+
+// Directory is a directory created by Install for the service.
+type Directory struct {
+	Path string
+	Mode os.FileMode // Defaults to 0755.
+
+	// Owner of the directory. UserName defaults to Config.UserName and
+	// GroupName to the primary group of the user. Ignored on Windows.
+	UserName  string
+	GroupName string
+}
+
+// directoryOptions are the options naming directories under the
+// directoryRoots, and the options for their modes.
+var directoryOptions = []struct {
+	name, mode string
+}{
+	{optionRuntimeDirectory, optionRuntimeDirectoryMode},
+	{optionStateDirectory, optionStateDirectoryMode},
+	{optionLogsDirectory, optionLogsDirectoryMode},
+}
+
+// directoryManager is implemented by services whose system creates the
+// directories of the RuntimeDirectory, StateDirectory and LogsDirectory
+// options itself.
+type directoryManager interface {
+	managesDirectories() bool
+}
+
+// optionDirectories returns the directories of the RuntimeDirectory,
+// StateDirectory and LogsDirectory options.
+func (c *Config) optionDirectories() []Directory {
+	var dirs []Directory
+	for _, o := range directoryOptions {
+		root, ok := directoryRoots[o.name]
+		if !ok {
+			continue
+		}
+		mode, _ := strconv.ParseUint(c.Option.string(o.mode, ""), 8, 32)
+		for _, name := range strings.Fields(c.Option.string(o.name, "")) {
+			dirs = append(dirs, Directory{Path: filepath.Join(root, name), Mode: os.FileMode(mode)})
+		}
+	}
+	return dirs
+}
+
+// makeDirectories creates the Directories of c, and the directories of the
+// RuntimeDirectory, StateDirectory and LogsDirectory options unless the
+// system of s manages them. The ones that did not exist are recorded for
+// Uninstall.
+func (c *Config) makeDirectories(s Service) (err error) {
+	dirs := c.Directories
+	if m, ok := s.(directoryManager); !ok || !m.managesDirectories() {
+		dirs = append(dirs[:len(dirs):len(dirs)], c.optionDirectories()...)
+	}
+	var created []string
+	defer func() {
+		if len(created) > 0 {
+			if recordErr := recordDirectories(c.Name, created); err == nil {
+				err = recordErr
+			}
+		}
+	}()
+	for _, d := range dirs {
+		mode := d.Mode
+		if mode == 0 {
+			mode = 0755
+		}
+		_, statErr := os.Stat(d.Path)
+		if err := os.MkdirAll(d.Path, mode); err != nil {
+			return privilegeError(err)
+		}
+		if os.IsNotExist(statErr) {
+			created = append(created, filepath.Clean(d.Path))
+		}
+		// MkdirAll is subject to the umask and leaves existing directories.
+		if err := os.Chmod(d.Path, mode); err != nil {
+			return privilegeError(err)
+		}
+		userName := d.UserName
+		if userName == "" {
+			userName = c.UserName
+		}
+		if err := chown(d.Path, userName, d.GroupName); err != nil {
+			return privilegeError(err)
+		}
+	}
+	return nil
+}
+
 // KeyValue provides a list of system specific options.
 //
 //   - OS X
@@ -180,6 +739,17 @@ func New(i Interface, c *Config) (Service, error) {
 //
 //   - SessionCreate bool   (false)            - Create a full user session.
 //
+//   - GroupName     string ()                 - Run the daemon as this group, used with Config.UserName.
+//
+//   - KeepAlivePathState       map[string]bool () - Keep alive while the path exists (true) or is missing (false).
+//     When set, KeepAlive is rendered as a dictionary of conditions instead of a bool.
+//
+//   - KeepAliveOtherJobEnabled map[string]bool () - Keep alive while the labeled job is loaded (true) or not (false).
+//
+//   - StartOnMount  bool   (false)            - Start the job every time a filesystem is mounted.
+//
+//   - StandardInPath string ()                - File to use as the standard input of the job.
+//
 //   - Solaris
 //
 //   - Prefix        string ("application")    - Service FMRI prefix.
@@ -209,18 +779,149 @@ func New(i Interface, c *Config) (Service, error) {
 //   - SuccessExitStatus string ()             - The list of exit status that shall be considered as successful,
 //     in addition to the default ones.
 //
+//   - RestartPreventExitStatus string ()      - Exit statuses and signals after which systemd does not restart the
+//     service, such as "78" for a configuration error, so a misconfigured service does not restart in a loop.
+//
 //   - LogDirectory string(/var/log)           - The path to the log files directory
 //
+//   - StartType     string ("automatic")      - Enable the service to start at boot on install. (automatic | manual | disabled)
+//     With manual or disabled the service is installed but not enabled, see Enabler.
+//
+//   - CaptureOutput bool   (false)            - When run by the service manager, send lines written to
+//     os.Stdout and os.Stderr to the system logger as info and error messages.
+//
+//   - StopTimeout   string ()                 - When run interactively, Run stops waiting for Stop after this
+//     time.Duration string, returning ErrStopTimeout. A second interrupt or SIGTERM always stops the wait.
+//
+//   - LogFile       string ()                 - SystemLogger writes to this file instead of syslog, see NewFileLogger.
+//
+//   - LogFileMaxSize        int (10)          - Rotate the LogFile before it grows past this many megabytes.
+//
+//   - LogFileMaxBackups     int (0)           - Rotated files to keep, all if zero.
+//
+//   - LogFileMaxAge         string ()         - Remove rotated files older than this time.Duration string.
+//
+//   - LogFileRotateInterval string ()         - Also rotate the LogFile once it is this old, time.Duration string.
+//
+//   - Logger        string ()                 - "null" makes Logger and SystemLogger return NullLogger, for
+//     tests and programs that do their own logging.
+//
+//   - Watch         []string ()               - When run interactively, restart the program with Stop and Start
+//     when one of these files changes, such as a config file. Polled every second. Ignored by the service manager.
+//
+//   - RestartOnReload bool (false)            - On reload, restart the program with Stop and Start in the same
+//     process instead of letting the reload signal end it, for supervisors that reload rather than restart.
+//     Run waits for ReloadSignal, HUP by default, and systemd sends it on "systemctl reload". On Windows
+//     the service accepts the paramchange control, such as from "sc control <name> paramchange".
+//
+//   - ReapChildren  bool   (false)            - On Linux, when the program is PID 1, such as the entrypoint of a
+//     container, Run starts it again as a child and acts as its init: it forwards TERM, INT, HUP, QUIT, USR1
+//     and USR2 to the child, reaps orphaned processes and exits with the exit code of the child, so no
+//     separate init such as tini is needed. Ignored when the program is not PID 1, see InContainer.
+//
+//   - RemoveDirectories bool (false)          - Uninstall removes the Directories and the directories of the
+//     RuntimeDirectory, StateDirectory and LogsDirectory options when they are empty, leaving those with files.
+//     Only the directories Install created are removed, it records them in the user configuration directory.
+//
+//   - PurgeDirectories  bool (false)          - Uninstall removes the directories above with their content.
+//     Refused for relative paths and top level directories such as "/var".
+//
 //   - Linux (systemd)
 //
+//   - NotifyReload  bool   (false)            - Install a Type=notify-reload unit, systemd 253 or later. Run tells
+//     systemd when the program started and, with RestartOnReload which it needs, when a reload starts and
+//     ends, so "systemctl reload" waits for the restart instead of returning at once.
+//
+//   - DaemonReload  bool   (true)             - Run "systemctl daemon-reload" after Install, Uninstall and
+//     UpdateExecutable change a unit file. Set to false when installing a batch of services, then call
+//     DaemonReload once, see DaemonReloader. Until then systemd doesn't see the changed units.
+//
 //   - LimitNOFILE   int    (-1)               - Maximum open files (ulimit -n)
 //     (https://serverfault.com/questions/628610/increasing-nproc-for-processes-launched-by-systemd-on-centos-7)
 //
+//   - Overwrite     bool   (false)            - Install rewrites an existing unit file and reloads systemd
+//     instead of returning an error. A running service keeps its old configuration until restarted.
+//
+//   - DropIn        bool   (false)            - Do not write the unit, it is provided by a package. Instead write
+//     the user, environment, limits and restart policy to the <name>.service.d/override.conf drop-in.
+//     Uninstall disables the unit and removes only the drop-in.
+//
+//   - RuntimeDirectory string ()              - Space separated directories systemd creates under /run, and
+//     removes when the service stops. On other POSIX systems Install creates them under /var/run.
+//
+//   - StateDirectory   string ()              - Space separated directories under /var/lib, as above.
+//
+//   - LogsDirectory    string ()              - Space separated directories under /var/log, as above.
+//
+//   - RuntimeDirectoryMode, StateDirectoryMode, LogsDirectoryMode string ("0755") - Octal mode of the directories.
+//
+//   - Slice         string ()                 - Run the service in this slice unit, such as "myapp.slice".
+//
+//   - SliceConfig   string ()                 - Lines of the [Slice] section, such as "MemoryMax=1G". When set,
+//     Install also writes the slice unit if it does not exist. Uninstall leaves it for other services.
+//
+//   - ExecStartPre  []string ()               - Command lines run before the service starts, written as is,
+//     so they may use the systemd prefixes such as "-" to ignore a failure or "+" to run as root.
+//
+//   - ExecStartPost []string ()               - Command lines run after the service started, as above.
+//
+//   - TimeoutStartSec string ()               - Time systemd waits for the service to start, time.Duration
+//     string or "infinity". Defaults to the systemd default of 90s.
+//
+//   - TimeoutStopSec  string ()               - Time systemd waits for the service to stop before killing it,
+//     as above. Defaults to StopTimeout, so one option sets the stop timeout on every platform.
+//
+//   - KillMode      string ()                 - Which processes are killed on stop: control-group, mixed,
+//     process or none. Defaults to the systemd default of control-group.
+//
+//   - KillSignal    string ()                 - Signal sent to stop the service, such as "SIGINT". Defaults to SIGTERM.
+//
+//   - LoadCredential []string ()              - Credentials systemd passes to the service as "ID:PATH", such as
+//     "db-password:/etc/app/db-password", read by the service with Credential. Unlike environment variables
+//     they are only readable by the service.
+//
+//   - SetCredential  []string ()              - Credentials given inline as "ID:VALUE", as above. The values are
+//     written to the unit or drop-in, which Install then makes only readable by root, mode 0600.
+//
+//   - PartOf        []string ()               - Units that stop and restart the service with them.
+//
+//   - Target        string ()                 - Group the service under this target unit, such as "myapp.target",
+//     so "systemctl stop myapp.target" stops all its services. The service is part of and wanted by the target
+//     instead of multi-user.target. Install writes the target unit if it does not exist and enables it with
+//     the service. Uninstall leaves it for the other services.
+//
+//   - WantedBy      []string ()               - Targets that start the service when enabled, such as
+//     "graphical.target" or "sysinit.target". Defaults to Target, or multi-user.target unless RequiredBy is set.
+//
+//   - RequiredBy    []string ()               - Targets that fail to start without the service, as above.
+//
+//   - DefaultDependencies bool (true)         - Set to false for DefaultDependencies=no, which drops the implicit
+//     ordering after basic.target and before shutdown.target, for services running in early boot or late
+//     shutdown. Such services usually set WantedBy, such as "sysinit.target", and their own ordering in Dependencies.
+//
+//   - Conditions    []string ()               - Condition and Assert directives of the [Unit] section, such as
+//     "ConditionACPower=true" or "ConditionVirtualization=!container". systemd skips starting the service when a
+//     condition fails, and fails the start when an assertion fails.
+//
+//   - Nice          int    ()                 - CPU scheduling priority of the service, from -20 (highest) to 19
+//     (lowest). Written to the unit on systemd, on the other Linux systems Run sets it when run by the service manager.
+//
+//   - IOSchedulingClass    string ()          - IO scheduling class: realtime, best-effort or idle, such as idle
+//     for backup services that must not slow down other disk access.
+//
+//   - IOSchedulingPriority int    ()          - IO priority within the class, from 0 (highest) to 7 (lowest).
+//
+//   - CPUSchedulingPolicy   string ()         - CPU scheduling policy: other, batch, idle, fifo or rr. The real-time
+//     policies fifo and rr are for latency critical services, such as audio or machine control.
+//
+//   - CPUSchedulingPriority int    ()         - Real-time priority with fifo or rr, from 1 (lowest) to 99 (highest).
+//
 //   - Windows
 //
 //   - DelayedAutoStart  bool (false)                - After booting, start this service after some delay.
 //
 //   - Password  string ()                           - Password to use when interfacing with the system service manager.
+//     Not used for the LocalSystem, LocalService and NetworkService accounts and NT SERVICE\<name> virtual accounts.
 //
 //   - Interactive       bool (false)                - The service can interact with the desktop. (more information https://docs.microsoft.com/en-us/windows/win32/services/interactive-services)
 //
@@ -228,11 +929,68 @@ func New(i Interface, c *Config) (Service, error) {
 //
 //   - StartType               string ("automatic")  - Start service type. (automatic | manual | disabled)
 //
+//   - CaptureOutput           bool (false)          - When run by the service manager, send lines written to
+//     os.Stdout and os.Stderr to the event log as info and error messages.
+//
 //   - OnFailure               string ("restart" )   - Action to perform on service failure. (restart | reboot | noaction)
 //
 //   - OnFailureDelayDuration  string ( "1s" )       - Delay before restarting the service, time.Duration string.
 //
 //   - OnFailureResetPeriod    int ( 10 )            - Reset period for errors, seconds.
+//
+//   - OnFailureForNonCrash    bool (false)          - Also perform the OnFailure action when the service stops
+//     with a non-zero exit code, not only when it crashes.
+//
+//   - StopTimeout             string ()             - Time the service is given to stop, time.Duration string.
+//     Reported to the SCM as the wait hint while stopping. Defaults to WaitToKillServiceTimeout.
+//     Stop and Restart wait this long for the service to stop, then return ErrStopTimeout.
+//     When run interactively Run stops waiting for Stop after this time, or on a second interrupt or SIGTERM.
+//
+//   - Triggers                []Trigger ()          - Events that start or stop the service, see Trigger.
+//
+//   - LoadOrderGroup          string ()             - Load ordering group the service belongs to. Services in a
+//     group are started relative to the groups listed in ServiceGroupOrder.
+//
+//   - RequestTag              bool (false)          - Have the SCM assign a tag to order the service within
+//     its LoadOrderGroup, read it back with Tag.
+//
+//   - RemoveDirectories, PurgeDirectories - As on POSIX, for the Directories.
+//
+//   - LogFile, LogFileMaxSize, LogFileMaxBackups, LogFileMaxAge, LogFileRotateInterval - As on POSIX,
+//     SystemLogger writes to the file instead of the event log.
+//
+//   - Logger                  string ()             - As on POSIX, "null" selects NullLogger.
+//
+//   - PreferredNode           int (-1)              - NUMA node to run the service on. Not set when negative.
+//
+//   - AllowStartStop          []string ()           - Accounts or SIDs, such as "S-1-5-19", allowed to query, start
+//     and stop the service without being administrators.
+//
+//   - StopDependents          bool (false)          - Uninstall stops the services that depend on the service
+//     instead of returning ErrHasDependents. They fail to start until the service is installed again.
+//
+//   - SuffixDisplayName       bool (false)          - When another service has the same display name, Install
+//     appends the service name, as "Display Name (name)", instead of returning ErrDisplayNameInUse.
+//
+//   - VirtualAccount          bool (false)          - Run the service as its NT SERVICE\<name> virtual account,
+//     an identity of its own without a password. UserName must be empty.
+//
+//   - PreshutdownTimeout      string ()             - Time the service is given to stop when the computer shuts down,
+//     time.Duration string. The service then stops on the preshutdown notification, with this time of its own
+//     instead of the machine wide WaitToKillServiceTimeout. Stored with the service, so Uninstall removes it.
+//
+//   - InstallScript           string ()             - Path of a batch file Install writes the sc.exe and reg.exe commands
+//     to, instead of changing the SCM and registry, for an installer to run later. The file holds the Password
+//     option in clear text. Triggers, AllowStartStop, RequestTag and SuffixDisplayName are not supported.
+//
+//   - InstallSettleTimeout    string (2s)           - How long Install retries the steps after creating the service,
+//     such as setting recovery actions and adding the event source, when they fail until the SCM and registry
+//     catch up. time.Duration string.
+//
+//   - LoadUserEnvironment     bool (false)          - When run by the SCM, load the profile of the service account
+//     with LoadUserProfile and take its environment variables, such as PATH and TEMP, like a program the user
+//     starts would. The service Environment and EnvVars still take precedence. Loading the profile needs an
+//     administrator or LocalSystem, other accounts get the environment without it and a warning is logged.
 type KeyValue map[string]interface{}
 
 // bool returns the value of the given name, assuming the value is a boolean.
@@ -279,6 +1037,31 @@ func (kv KeyValue) float64(name string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// boolMap returns the value of the given name, assuming the value is a map[string]bool.
+// If the value isn't found or is not of the type, nil is returned.
+func (kv KeyValue) boolMap(name string) map[string]bool {
+	if v, found := kv[name]; found {
+		if castValue, is := v.(map[string]bool); is {
+			return castValue
+		}
+	}
+	return nil
+}
+
+// strings returns the value of the given name, assuming the value is a []string
+// or a single string. If the value isn't found or is not of the type, nil is returned.
+func (kv KeyValue) strings(name string) []string {
+	if v, found := kv[name]; found {
+		switch castValue := v.(type) {
+		case []string:
+			return castValue
+		case string:
+			return []string{castValue}
+		}
+	}
+	return nil
+}
+
 // funcSingle returns the value of the given name, assuming the value is a func().
 // If the value isn't found or is not of the type, the defaultValue is returned.
 func (kv KeyValue) funcSingle(name string, defaultValue func()) func() {
@@ -298,15 +1081,114 @@ func Platform() string {
 	return system.String()
 }
 
+// forcedInteractive is set by SetInteractive.
+var forcedInteractive *bool
+
+// InContainer reports whether the process runs in a container, so service
+// code can adapt, such as to reap children and handle SIGTERM itself when
+// it is PID 1. On Linux it checks for /.dockerenv and /run/.containerenv,
+// the container environment variable of systemd-nspawn and LXC, and docker
+// or lxc cgroups of PID 1. On Windows it checks the ContainerType Windows
+// sets in containers, elsewhere only the container environment variable.
+func InContainer() bool {
+	return inContainer()
+}
+
 // Interactive returns false if running under the OS service manager
-// and true otherwise.
+// and true otherwise, unless set by SetInteractive.
 func Interactive() bool {
+	if forcedInteractive != nil {
+		return *forcedInteractive
+	}
 	if system == nil {
 		return true
 	}
 	return system.Interactive()
 }
 
+// SetInteractive overrides the detection of Interactive, such as when a
+// custom host starts the program in a way the detection gets wrong. With
+// false Run runs the program as a service, with true it runs it in the
+// foreground. Call it before Run.
+func SetInteractive(interactive bool) {
+	forcedInteractive = &interactive
+}
+
+// statusBatcher is implemented by systems that can query the status of
+// several services at once.
+type statusBatcher interface {
+	statuses(names []string) (map[string]Status, error)
+}
+
+// Statuses returns the status of each named service. Services that are not
+// installed are StatusUnknown. On Windows the service manager is opened once
+// for all names and on systemd a single systemctl call is made, on the other
+// systems the status of each service is queried in turn.
+func Statuses(names ...string) (map[string]Status, error) {
+	if system == nil {
+		return nil, ErrNoServiceSystemDetected
+	}
+	if b, ok := system.(statusBatcher); ok {
+		return b.statuses(names)
+	}
+	return statusesOneByOne(names)
+}
+
+// nice returns the Nice option, and false when it is not set.
+func nice(kv KeyValue) (int, bool, error) {
+	if _, ok := kv[optionNice]; !ok {
+		return 0, false, nil
+	}
+	n := kv.int(optionNice, 0)
+	if n < -20 || n > 19 {
+		return 0, false, fmt.Errorf("Nice %d is not within -20..19", n)
+	}
+	return n, true, nil
+}
+
+// Credential returns the credential with the id that systemd passed to the
+// service with the LoadCredential or SetCredential option. It returns an
+// error matching os.ErrNotExist when there is no such credential, such as
+// when not run by systemd.
+func Credential(id string) ([]byte, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return nil, fmt.Errorf("credential %s: CREDENTIALS_DIRECTORY is not set: %w", id, os.ErrNotExist)
+	}
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid credential id %q", id)
+	}
+	return os.ReadFile(filepath.Join(dir, id))
+}
+
+// installed implements Installed on top of Status.
+func installed(s Service) (bool, error) {
+	_, err := s.Status()
+	if errors.Is(err, ErrNotInstalled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func statusesOneByOne(names []string) (map[string]Status, error) {
+	statuses := make(map[string]Status, len(names))
+	for _, name := range names {
+		s, err := system.New(nil, &Config{Name: name})
+		if err != nil {
+			return nil, err
+		}
+		status, err := s.Status()
+		if err != nil && err != ErrNotInstalled {
+			return nil, err
+		}
+		statuses[name] = status
+	}
+	return statuses, nil
+}
+
 func newSystem() System {
 	for _, choice := range systemRegistry {
 		if choice.Detect() == false {
@@ -387,6 +1269,424 @@ type Shutdowner interface {
 	Shutdown(s Service) error
 }
 
+// PreInstaller represents a service interface for a program that needs to
+// prepare the system before the service is installed or uninstalled, such as
+// creating directories or setting ACLs. An error aborts the operation.
+type PreInstaller interface {
+	Interface
+	PreInstall(s Service) error
+	PreUninstall(s Service) error
+}
+
+// PostInstaller represents a service interface for a program that needs to
+// finish setting up the system after the service is installed or
+// uninstalled. The error is returned from Install or Uninstall, the
+// service manager has already been changed.
+//
+// PostInstall is the place for first-run provisioning with the same binary,
+// such as generating keys or creating a database schema. Like PreInstaller,
+// it runs in the process calling Install, usually an administrator or root,
+// not as Config.UserName and not in the environment of the service. Files it
+// creates for the service must be given to the service account explicitly,
+// see Config.Directories.
+type PostInstaller interface {
+	Interface
+	PostInstall(s Service) error
+	PostUninstall(s Service) error
+}
+
+// HealthChecker represents a service interface for a program that can tell
+// if the running service is ready, such as by calling its HTTP endpoint.
+// Like PreInstaller it runs in the process controlling the service, see
+// WaitHealthy.
+type HealthChecker interface {
+	Interface
+	Healthy(s Service) error
+}
+
+// runInstall checks the name of c, creates its Directories and runs install,
+// the install steps of s, between the PreInstaller and PostInstaller hooks
+// of i.
+func runInstall(i Interface, s Service, c *Config, install func() error) error {
+	if err := validateName(s, c.Name); err != nil {
+		return err
+	}
+	if p, ok := i.(PreInstaller); ok {
+		if err := p.PreInstall(s); err != nil {
+			return err
+		}
+	}
+	if err := c.makeDirectories(s); err != nil {
+		return err
+	}
+	if err := install(); err != nil {
+		return err
+	}
+	if p, ok := i.(PostInstaller); ok {
+		return p.PostInstall(s)
+	}
+	return nil
+}
+
+// runUninstall runs uninstall, the uninstall steps of s, between the
+// PreInstaller and PostInstaller hooks of i, and removes the directories of
+// c with the RemoveDirectories or PurgeDirectories option.
+func runUninstall(i Interface, s Service, c *Config, uninstall func() error) error {
+	dirs, err := c.directoriesToRemove()
+	if err != nil {
+		return err
+	}
+	if p, ok := i.(PreInstaller); ok {
+		if err := p.PreUninstall(s); err != nil {
+			return err
+		}
+	}
+	if err := uninstall(); err != nil {
+		return err
+	}
+	if err := removeDirectories(dirs, c.Option.bool(optionPurgeDirectories, false)); err != nil {
+		return err
+	}
+	if len(dirs) > 0 {
+		if err := removeDirectoryRecord(c.Name); err != nil {
+			return err
+		}
+	}
+	if p, ok := i.(PostInstaller); ok {
+		return p.PostUninstall(s)
+	}
+	return nil
+}
+
+// Enabler is implemented by services that can be enabled to start at boot or
+// disabled after they are installed, without reinstalling them. Together with
+// the StartType option set to disabled a service can be installed, configured
+// and then enabled. Implemented on Windows, launchd, systemd, Upstart, OpenRC,
+// SysV and rcS.
+//
+// On Windows Disable sets the disabled start type so the service can't be
+// started, Enable sets the StartType option or automatic. On the other systems
+// a disabled service is not started at boot but can still be started.
+type Enabler interface {
+	Enable() error
+	Disable() error
+}
+
+// DelayedAutoStarter is implemented by services whose delayed automatic start
+// can be changed after they are installed, without reinstalling them and
+// losing settings such as the recovery actions. A delayed service starts
+// some time after the other automatic services at boot. Implemented on
+// Windows, see the DelayedAutoStart option.
+type DelayedAutoStarter interface {
+	SetDelayedAutoStart(delayed bool) error
+}
+
+// ConfigReloader is implemented by services whose service manager only reads
+// the installed configuration when the service is loaded. ReloadConfig makes
+// the service manager pick up a configuration that was changed in place, this
+// restarts the service if it is running.
+//
+//	if r, ok := s.(service.ConfigReloader); ok {
+//		err = r.ReloadConfig()
+//	}
+type ConfigReloader interface {
+	ReloadConfig() error
+}
+
+// ResourceUsage is the resource usage of a running service process.
+type ResourceUsage struct {
+	PID       int           // Process ID of the main service process.
+	RSS       uint64        // Resident memory in bytes.
+	CPUTime   time.Duration // User and system CPU time consumed.
+	StartTime time.Time     // When the process started.
+}
+
+// UsageReporter is implemented by services that can report the resource usage
+// of their running process. Usage returns ErrNotRunning if the service isn't running.
+// Implemented on Windows and Linux (systemd, Upstart, SysV and rcS).
+type UsageReporter interface {
+	Usage() (ResourceUsage, error)
+}
+
+// Killer is implemented by services whose process can be killed when it
+// doesn't respond to Stop, such as after Stop returned ErrStopTimeout. Kill
+// returns ErrNotRunning if the service isn't running. Implemented on Windows
+// and Linux (systemd, Upstart, SysV and rcS).
+type Killer interface {
+	Kill() error
+}
+
+// UniqueConfig returns a Config named prefix with a random suffix, for tests
+// that install real services and must not collide with tests running in
+// parallel or services left over by a crashed run. Remove the service with
+// ForceUninstall.
+func UniqueConfig(prefix string) (*Config, error) {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, err
+	}
+	return &Config{Name: fmt.Sprintf("%s_%x", prefix, b)}, nil
+}
+
+// forceUninstaller is implemented by services whose Uninstall stops the
+// service and can go on when that fails.
+type forceUninstaller interface {
+	forceUninstall() error
+}
+
+// ForceUninstall stops and uninstalls s whatever state it is in, such as to
+// recover from a stuck service or for test cleanup. When Stop fails a Killer
+// is killed, and Uninstall goes on regardless. On Windows dependent services
+// are stopped, and the event log source removed. A service that is not
+// installed is not an error.
+func ForceUninstall(s Service) error {
+	installed, err := s.Installed()
+	if err != nil || !installed {
+		return err
+	}
+	if f, ok := s.(forceUninstaller); ok {
+		return f.forceUninstall()
+	}
+	if status, err := s.Status(); err == nil && status == StatusRunning {
+		if err := s.Stop(); err != nil {
+			if k, ok := s.(Killer); ok {
+				if err := k.Kill(); err != nil && !errors.Is(err, ErrNotRunning) {
+					return err
+				}
+			}
+		}
+	}
+	if err := s.Uninstall(); err != nil && !errors.Is(err, ErrNotInstalled) {
+		return err
+	}
+	return nil
+}
+
+// CommandReader is implemented by services that can read the command line
+// of the installed service, to verify what the service manager will run.
+// InstalledCommand returns the executable path and the arguments after
+// undoing the quoting of the service manager. Implemented on Windows and
+// Linux (systemd).
+type CommandReader interface {
+	InstalledCommand() (path string, args []string, err error)
+}
+
+// configReader is implemented by services that can read the configuration
+// of the installed service.
+type configReader interface {
+	installedConfig() (*Config, error)
+}
+
+// InstalledConfig reads the configuration of the installed service with the
+// name, such as for a tool managing services installed by other programs.
+// It fills in what the system reports of the display name, description,
+// executable, arguments, user name, dependencies and the StartType option.
+// Pass it to New to get a Service. Implemented on Windows and Linux
+// (systemd), it returns ErrNotSupported on other systems.
+func InstalledConfig(name string) (*Config, error) {
+	if system == nil {
+		return nil, ErrNoServiceSystemDetected
+	}
+	s, err := system.New(nil, &Config{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	r, ok := s.(configReader)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return r.installedConfig()
+}
+
+// locator is implemented by services that can name what Install creates.
+type locator interface {
+	location() (string, error)
+}
+
+// Location returns what Install creates for s, such as to log it after
+// installing: the path of the systemd unit or drop-in, the launchd plist or
+// the init script, or the registry key of the Windows service. It doesn't
+// check the service is installed. Returns ErrNotSupported on systems that
+// don't tell.
+func Location(s Service) (string, error) {
+	l, ok := s.(locator)
+	if !ok {
+		return "", ErrNotSupported
+	}
+	return l.location()
+}
+
+// DaemonReloader is implemented by services whose system caches the service
+// configuration. DaemonReload makes it reload the configuration of every
+// service, such as after installing several with the DaemonReload option
+// set to false. Implemented on Linux (systemd).
+type DaemonReloader interface {
+	DaemonReload() error
+}
+
+// DependentsLister is implemented by services that can list the installed
+// services that depend on them, in the order they should be stopped.
+// Implemented on Windows.
+type DependentsLister interface {
+	Dependents() ([]string, error)
+}
+
+// StatusInfo summarizes the state of an installed service, such as for the
+// output of a status command. It can be marshaled directly, Status is
+// marshaled as its name and Uptime as nanoseconds.
+type StatusInfo struct {
+	Name      string        `json:"name"`
+	Status    Status        `json:"status"`
+	PID       int           `json:"pid,omitempty"`
+	Uptime    time.Duration `json:"uptime,omitempty"`
+	StartType string        `json:"start_type,omitempty"` // automatic, manual or disabled.
+	Enabled   *bool         `json:"enabled,omitempty"`    // Starts at boot.
+	Text      string        `json:"text,omitempty"`       // Status text, see StatusTexter.
+}
+
+// startTyper is implemented by services that can read the start type of the
+// installed service.
+type startTyper interface {
+	installedStartType() (string, error)
+}
+
+// Inspect returns the StatusInfo of s. Only the status is required, the
+// other fields are left empty where the system does not report them or
+// reading them fails: PID and Uptime need a UsageReporter, StartType and
+// Enabled are read on systemd, OpenRC, Upstart and Windows, Text needs a
+// StatusTexter.
+func Inspect(s Service) (StatusInfo, error) {
+	info := StatusInfo{Name: s.Name()}
+	status, err := s.Status()
+	if err != nil {
+		return info, err
+	}
+	info.Status = status
+
+	if r, ok := s.(UsageReporter); ok && status == StatusRunning {
+		if usage, err := r.Usage(); err == nil {
+			info.PID = usage.PID
+			if !usage.StartTime.IsZero() {
+				info.Uptime = time.Since(usage.StartTime).Truncate(time.Second)
+			}
+		}
+	}
+	if t, ok := s.(startTyper); ok {
+		if startType, err := t.installedStartType(); err == nil {
+			enabled := startType == ServiceStartAutomatic
+			info.StartType = startType
+			info.Enabled = &enabled
+		}
+	}
+	if t, ok := s.(StatusTexter); ok {
+		if text, err := t.StatusText(); err == nil {
+			info.Text = text
+		}
+	}
+	return info, nil
+}
+
+// StatusTexter is implemented by services that report a human readable
+// status, such as the "Reindexing 42%" a systemd service published with
+// sd_notify STATUS=. StatusText returns an empty string when there is none.
+// Implemented on Linux (systemd) and Windows, where the text is the
+// checkpoint of a pending start or stop, or the exit code of a failed
+// service.
+type StatusTexter interface {
+	StatusText() (string, error)
+}
+
+// UnitState is the raw state of a systemd unit, which tells a failed service
+// apart from a cleanly stopped one.
+type UnitState struct {
+	ActiveState string // Such as "active", "activating", "deactivating", "inactive" or "failed".
+	SubState    string // Such as "running", "exited", "dead", "failed" or "auto-restart".
+}
+
+// UnitStater is implemented by services that report the state of their unit.
+// UnitState returns ErrNotInstalled if the unit isn't loaded.
+// Implemented on Linux (systemd).
+type UnitStater interface {
+	UnitState() (UnitState, error)
+}
+
+// State is a step in the lifetime of a running service.
+type State byte
+
+// States reported to a StateObserver.
+const (
+	StateStartPending State = iota + 1
+	StateRunning
+	StateStopPending
+	StateStopped
+)
+
+func (st State) String() string {
+	switch st {
+	case StateStartPending:
+		return "start pending"
+	case StateRunning:
+		return "running"
+	case StateStopPending:
+		return "stop pending"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// StateObserver represents a service interface for a program that wants to be
+// told about the state changes of the service while it runs, such as to update
+// metrics or health without polling the service manager.
+type StateObserver interface {
+	Interface
+	// StateChanged is called from Run on each change of state. It must not block.
+	StateChanged(s Service, state State)
+}
+
+// notifyState calls StateChanged if the program is a StateObserver.
+func notifyState(i Interface, s Service, state State) {
+	if o, ok := i.(StateObserver); ok {
+		o.StateChanged(s, state)
+	}
+}
+
+// stopInteractive runs stop, the Stop of the program. When the program is run
+// interactively a second interrupt or SIGTERM, or the StopTimeout option passing,
+// returns an error without waiting for a hung Stop, so Run returns and the
+// program can exit.
+func stopInteractive(kv KeyValue, stop func() error) error {
+	if !Interactive() {
+		return stop()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stop()
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+
+	var timeout <-chan time.Time
+	if d, err := time.ParseDuration(kv.string(optionStopTimeout, "")); err == nil && d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-sigChan:
+		return errors.New("stop interrupted")
+	case <-timeout:
+		return fmt.Errorf("stop did not finish within %s: %w", kv.string(optionStopTimeout, ""), ErrStopTimeout)
+	}
+}
+
 // TODO: Add Configure to Service interface.
 
 // Service represents a service that can be run or controlled.
@@ -416,13 +1716,18 @@ type Service interface {
 	// Opens and returns a system logger. If the user program is running
 	// interactively rather then as a service, the returned logger will write to
 	// os.Stderr. If errs is non-nil errors will be sent on errs as well as
-	// returned from Logger's functions.
+	// returned from Logger's functions. Logging never blocks on errs, errors
+	// are dropped when it is not ready to receive, so it should be buffered.
 	Logger(errs chan<- error) (Logger, error)
 
 	// SystemLogger opens and returns a system logger. If errs is non-nil errors
-	// will be sent on errs as well as returned from Logger's functions.
+	// will be sent on errs as well as returned from Logger's functions, as
+	// for Logger.
 	SystemLogger(errs chan<- error) (Logger, error)
 
+	// Name returns the name the service is registered with, Config.Name.
+	Name() string
+
 	// String displays the name of the service. The display name if present,
 	// otherwise the name.
 	String() string
@@ -433,12 +1738,149 @@ type Service interface {
 
 	// Status returns the current service status.
 	Status() (Status, error)
+
+	// Installed reports whether the service is installed. A service that is
+	// not installed returns false and a nil error, the error is reserved for
+	// failures such as missing privileges.
+	Installed() (bool, error)
+}
+
+// Accepted is the set of controls a running service accepts.
+type Accepted uint32
+
+// Controls a running service may accept.
+const (
+	AcceptStop Accepted = 1 << iota
+	AcceptPauseContinue
+	AcceptShutdown
+	AcceptPreShutdown
+)
+
+// controlAccepter is implemented by services that can query the controls
+// the running service accepts from the service manager.
+type controlAccepter interface {
+	accepts() (Accepted, error)
+}
+
+// Accepts returns the controls the running service accepts, such as to
+// decide which actions to offer in a management tool. ErrNotRunning is
+// returned if the service isn't running.
+//
+// On Windows these are the controls the service reported to the SCM. On
+// systemd stop depends on the unit, on the other systems a running service
+// always accepts stop and shutdown.
+func Accepts(s Service) (Accepted, error) {
+	if a, ok := s.(controlAccepter); ok {
+		return a.accepts()
+	}
+	status, err := s.Status()
+	if err != nil {
+		return 0, err
+	}
+	if status != StatusRunning {
+		return 0, ErrNotRunning
+	}
+	return AcceptStop | AcceptShutdown, nil
+}
+
+// tagger is implemented by services that can have a load order tag.
+type tagger interface {
+	tag() (uint32, error)
+}
+
+// Tag returns the tag the SCM assigned to the service within its load order
+// group when installed with the RequestTag option, or zero if it has none.
+// The tag orders the service in the GroupOrderList of the group.
+// ErrNotSupported is returned on systems other than Windows.
+func Tag(s Service) (uint32, error) {
+	if t, ok := s.(tagger); ok {
+		return t.tag()
+	}
+	return 0, ErrNotSupported
+}
+
+// installedComparer is implemented by services that can compare the
+// installed configuration with their Config.
+type installedComparer interface {
+	// installedMatches returns ErrNotInstalled if the service isn't installed.
+	installedMatches() (bool, error)
+}
+
+// InstallIfChanged installs the service if it is not installed, and
+// uninstalls and reinstalls it if the installed binary path, arguments,
+// account or start type differ from its Config. When the installed service
+// already matches it does nothing. It reports if the service was installed.
+// A running service is not restarted.
+//
+// The configuration is compared on systemd, launchd and Windows, on the other
+// systems an installed service is always reinstalled.
+func InstallIfChanged(s Service) (bool, error) {
+	var err error
+	if c, ok := s.(installedComparer); ok {
+		var matches bool
+		matches, err = c.installedMatches()
+		if err == nil && matches {
+			return false, nil
+		}
+	} else {
+		_, err = s.Status()
+	}
+	if err == ErrNotInstalled {
+		return true, s.Install()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err = s.Uninstall(); err != nil {
+		return false, err
+	}
+	return true, s.Install()
+}
+
+// executableUpdater is implemented by services that can change the command
+// of the installed service in place.
+type executableUpdater interface {
+	// updateExecutable returns ErrNotInstalled if the service isn't installed.
+	updateExecutable() error
+}
+
+// UpdateExecutable points the installed service at the Executable and
+// Arguments of its Config, such as after an upgrade placed the new binary at
+// a new path. Unlike uninstalling and reinstalling, the rest of the installed
+// configuration, such as the recovery actions and event log source on
+// Windows, is kept. With restart a running service is restarted to run the
+// new binary. Implemented on Windows and Linux (systemd), it returns
+// ErrNotSupported on other systems, use InstallIfChanged there.
+func UpdateExecutable(s Service, restart bool) error {
+	u, ok := s.(executableUpdater)
+	if !ok {
+		return ErrNotSupported
+	}
+	if err := u.updateExecutable(); err != nil {
+		return err
+	}
+	if !restart {
+		return nil
+	}
+	status, err := s.Status()
+	if err != nil || status != StatusRunning {
+		return err
+	}
+	return s.Restart()
 }
 
 // ControlAction list valid string texts to use in Control.
 var ControlAction = [5]string{"start", "stop", "restart", "install", "uninstall"}
 
-// Control issues control functions to the service from a given action string.
+// StatusAction is the action of Control that checks the service is running.
+// It is kept out of ControlAction so the type of ControlAction doesn't change.
+const StatusAction = "status"
+
+// Control issues control functions to the service from a given action string,
+// such as a command line argument. The status action returns nil if the
+// service is running and ErrNotRunning if it is installed but not running.
+// An unknown action returns an error listing the valid actions.
 func Control(s Service, action string) error {
 	var err error
 	switch action {
@@ -452,11 +1894,17 @@ func Control(s Service, action string) error {
 		err = s.Install()
 	case ControlAction[4]:
 		err = s.Uninstall()
+	case StatusAction:
+		var status Status
+		status, err = s.Status()
+		if err == nil && status != StatusRunning {
+			err = ErrNotRunning
+		}
 	default:
-		err = fmt.Errorf("Unknown action %s", action)
+		return fmt.Errorf("Unknown action %q, valid actions are: %s", action, strings.Join(append(ControlAction[:], StatusAction), ", "))
 	}
 	if err != nil {
-		return fmt.Errorf("Failed to %s %v: %v", action, s, err)
+		return fmt.Errorf("Failed to %s %v: %w", action, s, err)
 	}
 	return nil
 }
@@ -470,4 +1918,58 @@ type Logger interface {
 	Errorf(format string, a ...interface{}) error
 	Warningf(format string, a ...interface{}) error
 	Infof(format string, a ...interface{}) error
+
+	// With returns a Logger that adds the fields to each message. They are
+	// journal fields on Linux when the journal is running, insertion strings
+	// on Windows and key=value pairs after the message otherwise.
+	With(fields map[string]interface{}) Logger
+}
+
+// sendError sends a logging error on errs without blocking. The error is
+// dropped when errs is nil or not ready to receive, it is still returned by
+// the Logger method.
+func sendError(errs chan<- error, err error) {
+	if err == nil || errs == nil {
+		return
+	}
+	select {
+	case errs <- err:
+	default:
+	}
+}
+
+// withFields returns the fields of a and b, b taking precedence, without
+// changing either.
+func withFields(a, b map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		fields[k] = v
+	}
+	for k, v := range b {
+		fields[k] = v
+	}
+	return fields
+}
+
+// fieldPairs returns the fields as key=value strings sorted by key. Values
+// with spaces or quotes are quoted.
+func fieldPairs(fields map[string]interface{}) []string {
+	pairs := make([]string, 0, len(fields))
+	for k, v := range fields {
+		value := fmt.Sprint(v)
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = strconv.Quote(value)
+		}
+		pairs = append(pairs, k+"="+value)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+// formatFields returns the fields as key=value pairs to add to a message.
+func formatFields(fields map[string]interface{}) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	return " " + strings.Join(fieldPairs(fields), " ")
 }
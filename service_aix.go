@@ -86,7 +86,11 @@ func (s *aixService) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *aixService) Name() string {
+	return s.Config.Name
 }
 
 func (s *aixService) Platform() string {
@@ -117,13 +121,21 @@ func (s *aixService) configPath() (cp string, err error) {
 	return
 }
 
+func (s *aixService) location() (string, error) {
+	return s.configPath()
+}
+
 func (s *aixService) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *aixService) install() error {
 	// install service
 	path, err := s.execPath()
 	if err != nil {
 		return err
 	}
-	err = run("mkssys", "-s", s.Name, "-p", path, "-u", "0", "-R", "-Q", "-S", "-n", "15", "-f", "9", "-d", "-w", "30")
+	err = run("mkssys", "-s", s.Config.Name, "-p", path, "-u", "0", "-R", "-Q", "-S", "-n", "15", "-f", "9", "-d", "-w", "30")
 	if err != nil {
 		return err
 	}
@@ -140,7 +152,7 @@ func (s *aixService) Install() error {
 
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
@@ -165,10 +177,10 @@ func (s *aixService) Install() error {
 		rcd = "/etc/rc.d/rc"
 	}
 	for _, i := range [...]string{"2", "3"} {
-		if err = os.Symlink(confPath, rcd+i+".d/S50"+s.Name); err != nil {
+		if err = os.Symlink(confPath, rcd+i+".d/S50"+s.Config.Name); err != nil {
 			continue
 		}
-		if err = os.Symlink(confPath, rcd+i+".d/K02"+s.Name); err != nil {
+		if err = os.Symlink(confPath, rcd+i+".d/K02"+s.Config.Name); err != nil {
 			continue
 		}
 	}
@@ -177,9 +189,13 @@ func (s *aixService) Install() error {
 }
 
 func (s *aixService) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *aixService) uninstall() error {
 	s.Stop()
 
-	err := run("rmssys", "-s", s.Name)
+	err := run("rmssys", "-s", s.Config.Name)
 	if err != nil {
 		return err
 	}
@@ -192,14 +208,14 @@ func (s *aixService) Uninstall() error {
 }
 
 func (s *aixService) Status() (Status, error) {
-	exitCode, out, err := runWithOutput("lssrc", "-s", s.Name)
+	exitCode, out, err := runWithOutput("lssrc", "-s", s.Config.Name)
 	if exitCode == 0 && err != nil {
 		if !strings.Contains(err.Error(), "failed with stderr") {
 			return StatusUnknown, err
 		}
 	}
 
-	re := regexp.MustCompile(`\s+` + s.Name + `\s+(\w+\s+)?(\d+\s+)?(\w+)`)
+	re := regexp.MustCompile(`\s+` + s.Config.Name + `\s+(\w+\s+)?(\d+\s+)?(\w+)`)
 	matches := re.FindStringSubmatch(out)
 	if len(matches) == 4 {
 		status := string(matches[3])
@@ -225,11 +241,15 @@ func (s *aixService) Status() (Status, error) {
 	return StatusUnknown, ErrNotInstalled
 }
 
+func (s *aixService) Installed() (bool, error) {
+	return installed(s)
+}
+
 func (s *aixService) Start() error {
-	return run("startsrc", "-s", s.Name)
+	return run("startsrc", "-s", s.Config.Name)
 }
 func (s *aixService) Stop() error {
-	return run("stopsrc", "-s", s.Name)
+	return run("stopsrc", "-s", s.Config.Name)
 }
 func (s *aixService) Restart() error {
 	err := s.Stop()
@@ -241,30 +261,42 @@ func (s *aixService) Restart() error {
 }
 
 func (s *aixService) Run() error {
-	var err error
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
 
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *aixService) Logger(errs chan<- error) (Logger, error) {
-	if interactive {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 func (s *aixService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 var svcConfig = `#!/bin/ksh
@@ -5,8 +5,10 @@
 package service
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"os/user"
@@ -22,6 +24,10 @@ const maxPathSize = 32 * 1024
 const (
 	version                   = "darwin-launchd"
 	defaultDarwinLogDirectory = "/var/log"
+
+	// bootoutTimeout is how long ReloadConfig waits for launchd to unload
+	// the job before bootstrapping it again.
+	bootoutTimeout = 10 * time.Second
 )
 
 type darwinSystem struct{}
@@ -79,7 +85,11 @@ func (s *darwinLaunchdService) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *darwinLaunchdService) Name() string {
+	return s.Config.Name
 }
 
 func (s *darwinLaunchdService) Platform() string {
@@ -103,9 +113,13 @@ func (s *darwinLaunchdService) getHomeDir() (string, error) {
 func (s *darwinLaunchdService) getServiceFilePath() (string, error) {
 	if s.userService {
 		// for all users
-		return "/Library/LaunchAgents/" + s.Name + ".plist", nil
+		return "/Library/LaunchAgents/" + s.Config.Name + ".plist", nil
 	}
-	return "/Library/LaunchDaemons/" + s.Name + ".plist", nil
+	return "/Library/LaunchDaemons/" + s.Config.Name + ".plist", nil
+}
+
+func (s *darwinLaunchdService) location() (string, error) {
+	return s.getServiceFilePath()
 }
 
 func (s *darwinLaunchdService) logDir() (string, error) {
@@ -127,7 +141,7 @@ func (s *darwinLaunchdService) getLogPaths() (string, string, error) {
 }
 
 func (s *darwinLaunchdService) getLogPath(logDir, logType string) string {
-	return fmt.Sprintf("%s/%s.%s.log", logDir, s.Name, logType)
+	return fmt.Sprintf("%s/%s.%s.log", logDir, s.Config.Name, logType)
 }
 
 func (s *darwinLaunchdService) getActiveConsoleUserID() (string, error) {
@@ -138,6 +152,60 @@ func (s *darwinLaunchdService) getActiveConsoleUserID() (string, error) {
 	return strings.TrimSuffix(out, "\n"), nil
 }
 
+// domain returns the launchctl domain target the service is loaded in,
+// "system" for daemons, which launchd runs as their UserName, and
+// "gui/<uid>" for agents. Agents run as the user of their session, the
+// UserName if set, otherwise the console user.
+func (s *darwinLaunchdService) domain() (string, error) {
+	if !s.userService {
+		return "system", nil
+	}
+	if len(s.UserName) != 0 {
+		u, err := user.Lookup(s.UserName)
+		if err != nil {
+			return "", err
+		}
+		return "gui/" + u.Uid, nil
+	}
+	activeConsoleUser, err := s.getActiveConsoleUserID()
+	if err != nil {
+		return "", err
+	}
+	return "gui/" + activeConsoleUser, nil
+}
+
+// getMacOSVersion returns the product version of the running macOS or nil
+// if it can't be determined.
+func (s *darwinLaunchdService) getMacOSVersion() []int {
+	_, out, err := runWithOutput("sw_vers", "-productVersion")
+	if err != nil {
+		return nil
+	}
+
+	re := regexp.MustCompile(`^(\d+)(\.\d+)?(\.\d+)?`)
+	matches := re.FindStringSubmatch(strings.TrimSpace(out))
+	if len(matches) == 0 {
+		return nil
+	}
+
+	return parseVersion(matches[0])
+}
+
+// hasKickstart reports if launchctl supports "kickstart", added in OS X 10.10.
+func (s *darwinLaunchdService) hasKickstart() bool {
+	version := s.getMacOSVersion()
+	if version == nil {
+		return false
+	}
+
+	maxVersion := []int{10, 9, 99}
+	if matches, err := versionAtMost(version, maxVersion); err != nil || matches {
+		return false
+	}
+
+	return true
+}
+
 func (s *darwinLaunchdService) template() *template.Template {
 	functions := template.FuncMap{
 		"bool": func(v bool) string {
@@ -156,7 +224,26 @@ func (s *darwinLaunchdService) template() *template.Template {
 	return template.Must(template.New("").Funcs(functions).Parse(launchdConfig))
 }
 
+// validateAccount checks the user and group the daemon should run as exist.
+func (s *darwinLaunchdService) validateAccount() error {
+	if len(s.UserName) != 0 {
+		if _, err := user.Lookup(s.UserName); err != nil {
+			return fmt.Errorf("user %q for service %s: %v", s.UserName, s.Config.Name, err)
+		}
+	}
+	if groupName := s.Option.string(optionGroupName, ""); groupName != "" {
+		if _, err := user.LookupGroup(groupName); err != nil {
+			return fmt.Errorf("group %q for service %s: %v", groupName, s.Config.Name, err)
+		}
+	}
+	return nil
+}
+
 func (s *darwinLaunchdService) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *darwinLaunchdService) install() error {
 	confPath, err := s.getServiceFilePath()
 	if err != nil {
 		return err
@@ -166,12 +253,32 @@ func (s *darwinLaunchdService) Install() error {
 		return fmt.Errorf("Init already exists: %s", confPath)
 	}
 
+	if err := s.validateAccount(); err != nil {
+		return err
+	}
+
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
+	if err := s.writePlist(f); err != nil {
+		return err
+	}
+	if s.userService {
+		return nil
+	}
+	// launchd refuses to bootstrap a daemon, and so to drop privileges to its
+	// UserName, unless root owns the plist and only root can write it.
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	return privilegeError(f.Chown(0, 0))
+}
+
+// writePlist writes the launchd property list for the service to w.
+func (s *darwinLaunchdService) writePlist(w io.Writer) error {
 	path, err := s.execPath()
 	if err != nil {
 		return err
@@ -184,15 +291,29 @@ func (s *darwinLaunchdService) Install() error {
 
 		KeepAlive, RunAtLoad   bool
 		SessionCreate          bool
+		Disabled               bool
 		LimitLoadToSessionType string
 		StandardOutPath        string
 		StandardErrorPath      string
+		StandardInPath         string
+		GroupName              string
+		StartOnMount           bool
+
+		PathState       map[string]bool
+		OtherJobEnabled map[string]bool
 	}{
 		Config:        s.Config,
 		Path:          path,
 		KeepAlive:     s.Option.bool(optionKeepAlive, optionKeepAliveDefault),
 		RunAtLoad:     s.Option.bool(optionRunAtLoad, optionRunAtLoadDefault),
 		SessionCreate: s.Option.bool(optionSessionCreate, optionSessionCreateDefault),
+		Disabled:      s.Option.string(StartType, ServiceStartAutomatic) == ServiceStartDisabled,
+		GroupName:     s.Option.string(optionGroupName, ""),
+		StartOnMount:  s.Option.bool(optionStartOnMount, optionStartOnMountDefault),
+
+		StandardInPath:  s.Option.string(optionStandardInPath, ""),
+		PathState:       s.Option.boolMap(optionKeepAlivePathState),
+		OtherJobEnabled: s.Option.boolMap(optionKeepAliveOtherJobEnabled),
 	}
 
 	if s.userService {
@@ -202,10 +323,36 @@ func (s *darwinLaunchdService) Install() error {
 		to.StandardErrorPath = stdErrPath
 	}
 
-	return s.template().Execute(f, to)
+	return s.template().Execute(w, to)
+}
+
+// installedMatches compares the installed property list with what Install
+// would write.
+func (s *darwinLaunchdService) installedMatches() (bool, error) {
+	confPath, err := s.getServiceFilePath()
+	if err != nil {
+		return false, err
+	}
+	installed, err := os.ReadFile(confPath)
+	if os.IsNotExist(err) {
+		return false, ErrNotInstalled
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var want bytes.Buffer
+	if err := s.writePlist(&want); err != nil {
+		return false, err
+	}
+	return bytes.Equal(installed, want.Bytes()), nil
 }
 
 func (s *darwinLaunchdService) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *darwinLaunchdService) uninstall() error {
 	if err := s.Stop(); err != nil {
 		return err
 	}
@@ -221,16 +368,15 @@ func (s *darwinLaunchdService) Uninstall() error {
 	return nil
 }
 
+// lastExitCode matches the exit code of the last run in launchctl print.
+var lastExitCode = regexp.MustCompile(`last exit code\s*=\s*(-?\d+)`)
+
 func (s *darwinLaunchdService) Status() (Status, error) {
-	target := "system"
-	if s.userService {
-		activeConsoleUser, err := s.getActiveConsoleUserID()
-		if err != nil {
-			return StatusUnknown, err
-		}
-		target = "gui/" + activeConsoleUser
+	target, err := s.domain()
+	if err != nil {
+		return StatusUnknown, err
 	}
-	target = target + "/" + s.Name
+	target = target + "/" + s.Config.Name
 
 	exitCode, out, err := runWithOutput("launchctl", "print", target)
 	if exitCode == 0 && err != nil {
@@ -251,12 +397,19 @@ func (s *darwinLaunchdService) Status() (Status, error) {
 	}
 
 	if _, err = os.Stat(confPath); err == nil {
+		if m := lastExitCode.FindStringSubmatch(out); len(m) > 1 && m[1] != "0" {
+			return StatusFailed, nil
+		}
 		return StatusStopped, nil
 	}
 
 	return StatusUnknown, ErrNotInstalled
 }
 
+func (s *darwinLaunchdService) Installed() (bool, error) {
+	return installed(s)
+}
+
 func (s *darwinLaunchdService) Start() error {
 	status, _ := s.Status()
 	if status == StatusRunning {
@@ -268,13 +421,9 @@ func (s *darwinLaunchdService) Start() error {
 		return err
 	}
 
-	target := "system"
-	if s.userService {
-		activeConsoleUser, err := s.getActiveConsoleUserID()
-		if err != nil {
-			return err
-		}
-		target = "gui/" + activeConsoleUser
+	target, err := s.domain()
+	if err != nil {
+		return err
 	}
 
 	return run("launchctl", "bootstrap", target, confPath)
@@ -291,20 +440,27 @@ func (s *darwinLaunchdService) Stop() error {
 		return err
 	}
 
-	target := "system"
-	if s.userService {
-		activeConsoleUser, err := s.getActiveConsoleUserID()
-		if err != nil {
-			return err
-		}
-		target = "gui/" + activeConsoleUser
+	target, err := s.domain()
+	if err != nil {
+		return err
 	}
 
 	return run("launchctl", "bootout", target, confPath)
 }
 
+// Restart uses "launchctl kickstart -k" when the job is running and the
+// system supports it, so the job is never unloaded. Otherwise the job is
+// booted out and bootstrapped again.
 func (s *darwinLaunchdService) Restart() error {
 	status, _ := s.Status()
+	if status == StatusRunning && s.hasKickstart() {
+		target, err := s.domain()
+		if err != nil {
+			return err
+		}
+		return run("launchctl", "kickstart", "-k", target+"/"+s.Config.Name)
+	}
+
 	if status != StatusStopped {
 		err := s.Stop()
 		if err != nil {
@@ -316,30 +472,130 @@ func (s *darwinLaunchdService) Restart() error {
 	return s.Start()
 }
 
+// Enable overrides the Disabled key of the plist so the job can be loaded.
+func (s *darwinLaunchdService) Enable() error {
+	target, err := s.domain()
+	if err != nil {
+		return err
+	}
+	return run("launchctl", "enable", target+"/"+s.Config.Name)
+}
+
+func (s *darwinLaunchdService) Disable() error {
+	target, err := s.domain()
+	if err != nil {
+		return err
+	}
+	return run("launchctl", "disable", target+"/"+s.Config.Name)
+}
+
+// isLoaded reports if the job is loaded in its launchd domain.
+func (s *darwinLaunchdService) isLoaded() (bool, error) {
+	target, err := s.domain()
+	if err != nil {
+		return false, err
+	}
+	exitCode, _, err := runWithOutput("launchctl", "print", target+"/"+s.Config.Name)
+	if exitCode == 0 && err != nil {
+		if !strings.Contains(err.Error(), "failed with stderr") {
+			return false, err
+		}
+	}
+	return exitCode == 0 && err == nil, nil
+}
+
+// listed reports if launchctl list shows the job, which it stops doing once
+// launchd has unloaded it.
+func (s *darwinLaunchdService) listed() (bool, error) {
+	exitCode, _, err := runWithOutput("launchctl", "list", s.Config.Name)
+	if exitCode == 0 && err != nil {
+		if !strings.Contains(err.Error(), "failed with stderr") {
+			return false, err
+		}
+	}
+	return exitCode == 0 && err == nil, nil
+}
+
+// ReloadConfig boots the job out of its domain and bootstraps it again so
+// launchd reads the plist from disk, otherwise changes to the plist are
+// only seen on the next boot or login. A job that wasn't loaded is loaded.
+func (s *darwinLaunchdService) ReloadConfig() error {
+	confPath, err := s.getServiceFilePath()
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(confPath); err != nil {
+		return ErrNotInstalled
+	}
+
+	target, err := s.domain()
+	if err != nil {
+		return err
+	}
+
+	loaded, err := s.isLoaded()
+	if err != nil {
+		return err
+	}
+	if loaded {
+		if err = run("launchctl", "bootout", target, confPath); err != nil {
+			return err
+		}
+		// bootout returns before launchd has removed the job, and bootstrap
+		// fails while it is still there.
+		unloaded, err := poll(bootoutTimeout, func() (bool, error) {
+			listed, err := s.listed()
+			return !listed, err
+		})
+		if err != nil {
+			return err
+		}
+		if !unloaded {
+			return fmt.Errorf("%s still loaded %v after bootout", s.Config.Name, bootoutTimeout)
+		}
+	}
+
+	return run("launchctl", "bootstrap", target, confPath)
+}
+
 func (s *darwinLaunchdService) Run() error {
-	err := s.i.Start(s)
+	restore, err := captureOutput(s, s.Option)
 	if err != nil {
 		return err
 	}
+	defer restore()
+
+	notifyState(s.i, s, StateStartPending)
+	err = s.i.Start(s)
+	if err != nil {
+		notifyState(s.i, s, StateStopped)
+		return err
+	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *darwinLaunchdService) Logger(errs chan<- error) (Logger, error) {
-	if interactive {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 
 func (s *darwinLaunchdService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 var launchdConfig = `<?xml version="1.0" encoding="UTF-8"?>
@@ -347,7 +603,7 @@ var launchdConfig = `<?xml version="1.0" encoding="UTF-8"?>
 <plist version="1.0">
 <dict>
 	<key>Disabled</key>
-	<false/>
+	<{{bool .Disabled}}/>
 	{{- if .EnvVars}}
 	<key>EnvironmentVariables</key>
 	<dict>
@@ -358,7 +614,30 @@ var launchdConfig = `<?xml version="1.0" encoding="UTF-8"?>
 	</dict>
 	{{- end}}
 	<key>KeepAlive</key>
+	{{- if or .PathState .OtherJobEnabled}}
+	<dict>
+		{{- if .OtherJobEnabled}}
+		<key>OtherJobEnabled</key>
+		<dict>
+			{{- range $k, $v := .OtherJobEnabled}}
+			<key>{{html $k}}</key>
+			<{{bool $v}}/>
+			{{- end}}
+		</dict>
+		{{- end}}
+		{{- if .PathState}}
+		<key>PathState</key>
+		<dict>
+			{{- range $k, $v := .PathState}}
+			<key>{{html $k}}</key>
+			<{{bool $v}}/>
+			{{- end}}
+		</dict>
+		{{- end}}
+	</dict>
+	{{- else}}
 	<{{bool .KeepAlive}}/>
+	{{- end}}
 	<key>Label</key>
 	<string>{{html .Name}}</string>
 	<key>ProgramArguments</key>
@@ -378,10 +657,18 @@ var launchdConfig = `<?xml version="1.0" encoding="UTF-8"?>
 	<{{bool .RunAtLoad}}/>
 	<key>SessionCreate</key>
 	<{{bool .SessionCreate}}/>
+	{{- if .StartOnMount}}
+	<key>StartOnMount</key>
+	<true/>
+	{{- end}}
 	{{- if .LimitLoadToSessionType}}
 	<key>LimitLoadToSessionType</key>
 	<string>{{html .LimitLoadToSessionType}}</string>
 	{{- end}}
+	{{- if .StandardInPath}}
+	<key>StandardInPath</key>
+	<string>{{html .StandardInPath}}</string>
+	{{- end}}
 	{{- if .StandardErrorPath}}
 	<key>StandardErrorPath</key>
 	<string>{{html .StandardErrorPath}}</string>
@@ -393,6 +680,12 @@ var launchdConfig = `<?xml version="1.0" encoding="UTF-8"?>
 	{{- if .UserName}}
 	<key>UserName</key>
 	<string>{{html .UserName}}</string>
+	<key>InitGroups</key>
+	<true/>
+	{{- end}}
+	{{- if .GroupName}}
+	<key>GroupName</key>
+	<string>{{html .GroupName}}</string>
 	{{- end}}
 	{{- if .WorkingDirectory}}
 	<key>WorkingDirectory</key>
@@ -63,7 +63,11 @@ func (s *freebsdService) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *freebsdService) Name() string {
+	return s.Config.Name
 }
 
 func (s *freebsdService) Platform() string {
@@ -98,7 +102,15 @@ func (s *freebsdService) configPath() (cp string, err error) {
 	return
 }
 
+func (s *freebsdService) location() (string, error) {
+	return s.configPath()
+}
+
 func (s *freebsdService) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *freebsdService) install() error {
 	path, err := s.execPath()
 	if err != nil {
 		return err
@@ -116,7 +128,7 @@ func (s *freebsdService) Install() error {
 
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
@@ -141,6 +153,10 @@ func (s *freebsdService) Install() error {
 }
 
 func (s *freebsdService) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *freebsdService) uninstall() error {
 	cp, err := s.configPath()
 	if err != nil {
 		return err
@@ -158,7 +174,7 @@ func (s *freebsdService) Status() (Status, error) {
 		return StatusStopped, ErrNotInstalled
 	}
 
-	status, _, err := runCommand("service", false, s.Name, "status")
+	status, _, err := runCommand("service", false, s.Config.Name, "status")
 	if status == 1 {
 		return StatusStopped, nil
 	} else if err != nil {
@@ -167,44 +183,60 @@ func (s *freebsdService) Status() (Status, error) {
 	return StatusRunning, nil
 }
 
+func (s *freebsdService) Installed() (bool, error) {
+	return installed(s)
+}
+
 func (s *freebsdService) Start() error {
-	return run("service", s.Name, "start")
+	return run("service", s.Config.Name, "start")
 }
 
 func (s *freebsdService) Stop() error {
-	return run("service", s.Name, "stop")
+	return run("service", s.Config.Name, "stop")
 }
 
 func (s *freebsdService) Restart() error {
-	return run("service", s.Name, "restart")
+	return run("service", s.Config.Name, "restart")
 }
 
 func (s *freebsdService) Run() error {
-	var err error
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
 
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *freebsdService) Logger(errs chan<- error) (Logger, error) {
-	if interactive {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 
 func (s *freebsdService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 var rcScript = `#!/bin/sh
@@ -8,9 +8,21 @@ import (
 	"path/filepath"
 )
 
+// execPath returns the absolute path of the executable to install.
+//
+// An empty Executable is the current executable. A relative Executable is
+// resolved against WorkingDirectory when it is set, otherwise against the
+// current directory of the installing process. Symbolic links are not
+// resolved so a link can be repointed to a new binary on upgrade.
 func (c *Config) execPath() (string, error) {
-	if len(c.Executable) != 0 {
-		return filepath.Abs(c.Executable)
+	if len(c.Executable) == 0 {
+		return os.Executable()
 	}
-	return os.Executable()
+	if filepath.IsAbs(c.Executable) {
+		return filepath.Clean(c.Executable), nil
+	}
+	if len(c.WorkingDirectory) != 0 {
+		return filepath.Abs(filepath.Join(c.WorkingDirectory, c.Executable))
+	}
+	return filepath.Abs(c.Executable)
 }
@@ -0,0 +1,60 @@
+//go:build go1.8
+// +build go1.8
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecPath(t *testing.T) {
+	tmp := t.TempDir()
+	bin := filepath.Join(tmp, "bin")
+	if err := os.Mkdir(bin, 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(bin, "prog")
+	if err := os.WriteFile(target, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(tmp, "prog-link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlink: %v", err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		c    Config
+		want string
+	}{
+		{"current", Config{}, self},
+		{"absolute", Config{Executable: target}, target},
+		{"absolute unclean", Config{Executable: bin + "/../bin/prog"}, target},
+		{"absolute ignores working directory", Config{Executable: target, WorkingDirectory: wd}, target},
+		{"relative to working directory", Config{Executable: "bin/prog", WorkingDirectory: tmp}, target},
+		{"relative to dot", Config{Executable: "./prog", WorkingDirectory: bin}, target},
+		{"relative to current directory", Config{Executable: "prog"}, filepath.Join(wd, "prog")},
+		{"symlink kept", Config{Executable: "prog-link", WorkingDirectory: tmp}, link},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.c.execPath()
+			if err != nil {
+				t.Fatalf("execPath() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("execPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
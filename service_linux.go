@@ -6,19 +6,32 @@ package service
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 var cgroupFile = "/proc/1/cgroup"
 
+// containerFiles are created by container engines in the root of their
+// containers: /.dockerenv by Docker, /run/.containerenv by Podman.
+var containerFiles = []string{"/.dockerenv", "/run/.containerenv"}
+
 type linuxSystemService struct {
 	name        string
 	detect      func() bool
 	interactive func() bool
 	new         func(i Interface, platform string, c *Config) (Service, error)
+	batchStatus func(names []string) (map[string]Status, error)
+	listNames   func() ([]string, error)
 }
 
 func (sc linuxSystemService) String() string {
@@ -33,6 +46,18 @@ func (sc linuxSystemService) Interactive() bool {
 func (sc linuxSystemService) New(i Interface, c *Config) (Service, error) {
 	return sc.new(i, sc.String(), c)
 }
+func (sc linuxSystemService) statuses(names []string) (map[string]Status, error) {
+	if sc.batchStatus == nil {
+		return statusesOneByOne(names)
+	}
+	return sc.batchStatus(names)
+}
+func (sc linuxSystemService) list() ([]string, error) {
+	if sc.listNames == nil {
+		return nil, ErrNotSupported
+	}
+	return sc.listNames()
+}
 
 func init() {
 	ChooseSystem(linuxSystemService{
@@ -42,7 +67,9 @@ func init() {
 			is, _ := isInteractive()
 			return is
 		},
-		new: newSystemdService,
+		new:         newSystemdService,
+		batchStatus: systemdStatuses,
+		listNames:   systemdList,
 	},
 		linuxSystemService{
 			name:   "linux-upstart",
@@ -51,7 +78,8 @@ func init() {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newUpstartService,
+			new:       newUpstartService,
+			listNames: upstartList,
 		},
 		linuxSystemService{
 			name:   "linux-openrc",
@@ -60,7 +88,8 @@ func init() {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newOpenRCService,
+			new:       newOpenRCService,
+			listNames: initdList,
 		},
 		linuxSystemService{
 			name:   "linux-rcs",
@@ -69,7 +98,8 @@ func init() {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newRCSService,
+			new:       newRCSService,
+			listNames: initdList,
 		},
 		linuxSystemService{
 			name:   "unix-systemv",
@@ -78,11 +108,123 @@ func init() {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newSystemVService,
+			new:       newSystemVService,
+			listNames: initdList,
 		},
 	)
 }
 
+// initSignals are forwarded by runInit to the program.
+var initSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// validateUser checks the user the service should run as exists, before an
+// init script switching to it is installed.
+func (c *Config) validateUser() error {
+	if len(c.UserName) == 0 {
+		return nil
+	}
+	if _, err := user.Lookup(c.UserName); err != nil {
+		return fmt.Errorf("user %q for service %s: %v", c.UserName, c.Name, err)
+	}
+	return nil
+}
+
+// runInit makes the process the init of the program with the ReapChildren
+// option when it is PID 1: it starts the program again as a child, which
+// isn't PID 1 and runs as usual, and exits with its exit code when it is
+// gone. Otherwise it returns nil. The program must not reap the children
+// itself, os/exec would fail to wait for its commands.
+func runInit(kv KeyValue) error {
+	if !kv.bool(optionReapChildren, false) || os.Getpid() != 1 {
+		return nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	sigs := make(chan os.Signal, 16)
+	signal.Notify(sigs, append([]os.Signal{syscall.SIGCHLD}, initSignals...)...)
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err = cmd.Start(); err != nil {
+		signal.Stop(sigs)
+		return err
+	}
+	os.Exit(initLoop(cmd.Process.Pid, sigs))
+	return nil
+}
+
+// initLoop forwards the signals to pid and reaps every exited child until
+// pid exits, and returns its exit code, 128 plus the signal if it was
+// killed.
+func initLoop(pid int, sigs <-chan os.Signal) int {
+	for sig := range sigs {
+		if sig != syscall.SIGCHLD {
+			syscall.Kill(pid, sig.(syscall.Signal))
+			continue
+		}
+		for {
+			var status syscall.WaitStatus
+			reaped, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+			if err != nil || reaped <= 0 {
+				break
+			}
+			if reaped != pid {
+				continue
+			}
+			if status.Signaled() {
+				return 128 + int(status.Signal())
+			}
+			return status.ExitStatus()
+		}
+	}
+	return 0
+}
+
+// setNice sets the Nice option as the priority of every thread of the
+// process, new threads inherit it. It does nothing when run interactively.
+func setNice(kv KeyValue) error {
+	n, ok, err := nice(kv)
+	if err != nil || !ok || Interactive() {
+		return err
+	}
+	tasks, err := ioutil.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, n); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("set nice %d: %w", n, err)
+		}
+	}
+	return nil
+}
+
+// initdList returns the names of the scripts in /etc/init.d.
+func initdList() ([]string, error) {
+	return listDir("/etc/init.d", "")
+}
+
+// listDir returns the names of the files in dir with the suffix, without it.
+func listDir(dir, suffix string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), suffix) || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), suffix))
+	}
+	return names, nil
+}
+
 func binaryName(pid int) (string, error) {
 	statPath := fmt.Sprintf("/proc/%d/stat", pid)
 	dataBytes, err := ioutil.ReadFile(statPath)
@@ -97,6 +239,110 @@ func binaryName(pid int) (string, error) {
 	return data[binStart : binStart+binEnd], nil
 }
 
+// clockTicks is the USER_HZ used by the kernel for the times in /proc/<pid>/stat.
+const clockTicks = 100
+
+// bootTime reads the time the system booted from /proc/stat.
+func bootTime() (time.Time, error) {
+	b, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			sec, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, errors.New("btime not found in /proc/stat")
+}
+
+// processUsage reads the resource usage of the process from /proc.
+func processUsage(pid int) (ResourceUsage, error) {
+	statBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ResourceUsage{}, ErrNotRunning
+		}
+		return ResourceUsage{}, err
+	}
+	// The fields after the image name start with the state, utime and stime
+	// are the 14th and 15th fields of the file and starttime the 22nd.
+	stat := string(statBytes)
+	fields := strings.Fields(stat[strings.LastIndex(stat, ")")+1:])
+	if len(fields) < 20 {
+		return ResourceUsage{}, fmt.Errorf("unexpected format of /proc/%d/stat", pid)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+
+	starttime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	boot, err := bootTime()
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+
+	statmBytes, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	statm := strings.Fields(string(statmBytes))
+	if len(statm) < 2 {
+		return ResourceUsage{}, fmt.Errorf("unexpected format of /proc/%d/statm", pid)
+	}
+	resident, err := strconv.ParseUint(statm[1], 10, 64)
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+
+	return ResourceUsage{
+		PID:       pid,
+		RSS:       resident * uint64(os.Getpagesize()),
+		CPUTime:   time.Duration(utime+stime) * time.Second / clockTicks,
+		StartTime: boot.Add(time.Duration(starttime) * time.Second / clockTicks),
+	}, nil
+}
+
+// pidFromFile reads the PID from the PID file written by the init script.
+func pidFromFile(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, ErrNotRunning
+		}
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID file %s: %v", path, err)
+	}
+	if _, err := os.Stat(fmt.Sprintf("/proc/%d", pid)); err != nil {
+		return 0, ErrNotRunning
+	}
+	return pid, nil
+}
+
+// killProcess sends SIGKILL to the process.
+func killProcess(pid int) error {
+	err := syscall.Kill(pid, syscall.SIGKILL)
+	if err == syscall.ESRCH {
+		return ErrNotRunning
+	}
+	return privilegeError(err)
+}
+
 func isInteractive() (bool, error) {
 	inContainer, err := isInContainer(cgroupFile)
 	if err != nil {
@@ -116,6 +362,21 @@ func isInteractive() (bool, error) {
 	return binary != "systemd", nil
 }
 
+// inContainer checks the containerFiles, the container environment
+// variable and the cgroups of PID 1.
+func inContainer() bool {
+	for _, path := range containerFiles {
+		if _, err := os.Stat(path); err == nil {
+			return true
+		}
+	}
+	if os.Getenv("container") != "" {
+		return true
+	}
+	in, _ := isInContainer(cgroupFile)
+	return in
+}
+
 // isInContainer checks if the service is being executed in docker or lxc
 // container.
 func isInContainer(cgroupPath string) (bool, error) {
@@ -142,6 +403,12 @@ func isInContainer(cgroupPath string) (bool, error) {
 	return false, nil
 }
 
+// shellQuote quotes s as a single word for sh, in single quotes so nothing
+// inside is expanded.
+func shellQuote(s string) string {
+	return `'` + strings.Replace(s, `'`, `'\''`, -1) + `'`
+}
+
 var tf = map[string]interface{}{
 	"cmd": func(s string) string {
 		return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
@@ -149,4 +416,8 @@ var tf = map[string]interface{}{
 	"cmdEscape": func(s string) string {
 		return strings.Replace(s, " ", `\x20`, -1)
 	},
+	"shellQuote":   shellQuote,
+	"systemdArg":   systemdArg,
+	"systemdPath":  systemdPath,
+	"systemdValue": systemdValue,
 }
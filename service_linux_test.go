@@ -8,7 +8,13 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
 	"testing"
+	"time"
 )
 
 // createTestCgroupFiles creates mock files for tests
@@ -143,6 +149,78 @@ func Test_isInteractive(t *testing.T) {
 	}
 }
 
+func Test_processUsage(t *testing.T) {
+	usage, err := processUsage(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.PID != os.Getpid() {
+		t.Errorf("processUsage() PID = %d, want %d", usage.PID, os.Getpid())
+	}
+	if usage.RSS == 0 {
+		t.Error("processUsage() RSS = 0, want > 0")
+	}
+	if since := time.Since(usage.StartTime); since < 0 || since > time.Hour {
+		t.Errorf("processUsage() StartTime = %v, want shortly before now", usage.StartTime)
+	}
+
+	if _, err := processUsage(-1); err != ErrNotRunning {
+		t.Errorf("processUsage(-1) error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func Test_killProcess(t *testing.T) {
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	if err := killProcess(cmd.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Error("process exited normally, want killed")
+	}
+	if err := killProcess(cmd.Process.Pid); err != ErrNotRunning {
+		t.Errorf("killProcess() of exited process error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func Test_shellQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", `'plain'`},
+		{"two words", `'two words'`},
+		{"$HOME `id` \"x\"", `'$HOME ` + "`id`" + ` "x"'`},
+		{"it's", `'it'\''s'`},
+		{"", `''`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func Test_hasCapability(t *testing.T) {
+	f, err := ioutil.TempFile("", "status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeTestFile(f)
+	if _, err := f.WriteString("Name:\ttest\nCapPrm:\t0000000000000000\nCapEff:\t0000000000200000\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := hasCapability(f.Name(), capSysAdmin); err != nil || !ok {
+		t.Errorf("hasCapability(CAP_SYS_ADMIN) = %v, %v, want true", ok, err)
+	}
+	if ok, err := hasCapability(f.Name(), 0); err != nil || ok {
+		t.Errorf("hasCapability(0) = %v, %v, want false", ok, err)
+	}
+}
+
 const (
 	dockerCgroup = `13:name=systemd:/docker/bc9f0894926991e3064b731c26d86af6df7390c0e6453e6027f9545aba5809ee
 12:pids:/docker/bc9f0894926991e3064b731c26d86af6df7390c0e6453e6027f9545aba5809ee
@@ -171,3 +249,142 @@ const (
 1:name=systemd:/init.scope
 0::/init.scope`
 )
+
+func Test_watchReload(t *testing.T) {
+	p := &restartCounter{}
+	stopReload := watchReload(p, nil, KeyValue{optionRestartOnReload: true})
+	defer stopReload()
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&p.starts) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if starts, stops := atomic.LoadInt32(&p.starts), atomic.LoadInt32(&p.stops); starts != 1 || stops != 1 {
+		t.Errorf("got %d starts and %d stops, want 1 each", starts, stops)
+	}
+}
+
+func TestCredential(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	if got, err := Credential("db"); err != nil || string(got) != "secret" {
+		t.Errorf("Credential(db) = %q, %v, want secret", got, err)
+	}
+	if _, err := Credential("../db"); err == nil {
+		t.Error("Credential(../db) returned no error")
+	}
+
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	if _, err := Credential("db"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Credential() without CREDENTIALS_DIRECTORY error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func Test_inContainer(t *testing.T) {
+	hDockerGrp, hLinuxGrp, err := createTestCgroupFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		removeTestFile(hDockerGrp)
+		removeTestFile(hLinuxGrp)
+	}()
+	defer func(files []string, cgroup string) {
+		containerFiles, cgroupFile = files, cgroup
+	}(containerFiles, cgroupFile)
+
+	marker := filepath.Join(t.TempDir(), ".dockerenv")
+	t.Setenv("container", "")
+	containerFiles, cgroupFile = []string{marker}, hLinuxGrp.Name()
+	if inContainer() {
+		t.Error("inContainer() = true without any hint, want false")
+	}
+
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !inContainer() {
+		t.Error("inContainer() with a marker file = false, want true")
+	}
+	os.Remove(marker)
+
+	t.Setenv("container", "lxc")
+	if !inContainer() {
+		t.Error("inContainer() with the container variable = false, want true")
+	}
+	t.Setenv("container", "")
+
+	cgroupFile = hDockerGrp.Name()
+	if !inContainer() {
+		t.Error("inContainer() with docker cgroups = false, want true")
+	}
+}
+
+func TestValidateUser(t *testing.T) {
+	if err := (&Config{Name: "test"}).validateUser(); err != nil {
+		t.Errorf("validateUser() without UserName error = %v, want nil", err)
+	}
+	if err := (&Config{Name: "test", UserName: "root"}).validateUser(); err != nil {
+		t.Errorf("validateUser() of root error = %v, want nil", err)
+	}
+	if err := (&Config{Name: "test", UserName: "no-such-user-xyz"}).validateUser(); err == nil {
+		t.Error("validateUser() of a missing user returned no error")
+	}
+}
+
+func Test_initLoop(t *testing.T) {
+	sigs := make(chan os.Signal, 16)
+	signal.Notify(sigs, syscall.SIGCHLD)
+	defer signal.Stop(sigs)
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	if code := initLoop(cmd.Process.Pid, sigs); code != 3 {
+		t.Errorf("initLoop() = %d, want 3", code)
+	}
+
+	cmd = exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	sigs <- syscall.SIGTERM
+	if code := initLoop(cmd.Process.Pid, sigs); code != 128+int(syscall.SIGTERM) {
+		t.Errorf("initLoop() of a terminated child = %d, want %d", code, 128+int(syscall.SIGTERM))
+	}
+}
+
+func TestStopInteractiveSIGTERM(t *testing.T) {
+	if !Interactive() {
+		t.Skip("not running interactively")
+	}
+	// Keep a SIGTERM sent before stopInteractive listens from killing the test.
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, syscall.SIGTERM)
+	defer signal.Stop(guard)
+
+	hung := make(chan struct{})
+	defer close(hung)
+	done := make(chan error, 1)
+	go func() {
+		done <- stopInteractive(KeyValue{}, func() error { <-hung; return nil })
+	}()
+	for {
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Error("stopInteractive() after SIGTERM returned nil, want an error")
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
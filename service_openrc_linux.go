@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"regexp"
+	"strings"
 	"syscall"
 	"text/template"
 	"time"
@@ -48,7 +49,11 @@ func (s *openrc) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *openrc) Name() string {
+	return s.Config.Name
 }
 
 func (s *openrc) Platform() string {
@@ -84,7 +89,15 @@ func (s *openrc) configPath() (cp string, err error) {
 	return
 }
 
+func (s *openrc) location() (string, error) {
+	return s.configPath()
+}
+
 func (s *openrc) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *openrc) install() error {
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -93,10 +106,13 @@ func (s *openrc) Install() error {
 	if err == nil {
 		return fmt.Errorf("Init already exists: %s", confPath)
 	}
+	if err = s.Config.validateUser(); err != nil {
+		return err
+	}
 
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
@@ -114,55 +130,169 @@ func (s *openrc) Install() error {
 		*Config
 		Path         string
 		LogDirectory string
+		Depend       []string
 	}{
 		s.Config,
 		path,
 		s.Option.string(optionLogDirectory, defaultLogDirectory),
+		openRCDepend(s.Dependencies),
 	}
 
 	err = s.template().Execute(f, to)
 	if err != nil {
 		return err
 	}
+	if !s.startAtBoot() {
+		return nil
+	}
 	// run rc-update
 	return s.runAction("add")
 }
 
+// openRCDirectives maps the systemd style dependency keys onto the
+// OpenRC depend() directives. OpenRC stops the services that need a stopped
+// service, so BindsTo is need too. Requisite, which doesn't start the
+// dependency, has no directive and its lines are left out.
+var openRCDirectives = map[string]string{
+	"Requires":  "need",
+	"BindsTo":   "need",
+	"Requisite": "",
+	"Wants":     "use",
+	"After":     "after",
+	"Before":    "before",
+}
+
+// openRCDepend converts the Config.Dependencies into lines for the depend()
+// function. Lines using the systemd convention, such as "After=net",
+// are translated to their OpenRC directive. Any other line is passed through
+// as is, so native lines such as "need net" keep working.
+func openRCDepend(deps []string) []string {
+	lines := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		dep = strings.TrimSpace(dep)
+		if dep == "" {
+			continue
+		}
+		if key, value, ok := strings.Cut(dep, "="); ok {
+			if directive, found := openRCDirectives[strings.TrimSpace(key)]; found {
+				if directive == "" {
+					continue
+				}
+				units := strings.Fields(value)
+				for i, unit := range units {
+					units[i] = strings.TrimSuffix(unit, ".service")
+				}
+				if len(units) > 0 {
+					lines = append(lines, directive+" "+strings.Join(units, " "))
+				}
+				continue
+			}
+		}
+		lines = append(lines, dep)
+	}
+	return lines
+}
+
 func (s *openrc) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *openrc) uninstall() error {
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
 	}
+	enabled, err := s.enabled()
+	if err != nil {
+		return err
+	}
 	if err := os.Remove(confPath); err != nil {
 		return err
 	}
+	if !enabled {
+		return nil
+	}
+	return s.runAction("delete")
+}
+
+// enabled reports if the service was added to a runlevel.
+func (s *openrc) enabled() (bool, error) {
+	_, out, err := runWithOutput("rc-update", "show")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(out, "\n") {
+		name, levels, ok := strings.Cut(line, "|")
+		if ok && strings.TrimSpace(name) == s.Config.Name && strings.TrimSpace(levels) != "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *openrc) installedStartType() (string, error) {
+	enabled, err := s.enabled()
+	if err != nil {
+		return "", err
+	}
+	if enabled {
+		return ServiceStartAutomatic, nil
+	}
+	return ServiceStartManual, nil
+}
+
+func (s *openrc) Enable() error {
+	return s.runAction("add")
+}
+
+func (s *openrc) Disable() error {
 	return s.runAction("delete")
 }
 
 func (s *openrc) Logger(errs chan<- error) (Logger, error) {
-	if system.Interactive() {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 
 func (s *openrc) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 func (s *openrc) Run() (err error) {
+	if err = runInit(s.Option); err != nil {
+		return err
+	}
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	if err = setNice(s.Option); err != nil {
+		return err
+	}
+
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *openrc) Status() (Status, error) {
@@ -172,7 +302,7 @@ func (s *openrc) Status() (Status, error) {
 	// errno 2 = ENOENT 2 No such file or directory
 	// errno 3 = ESRCH 3 No such process
 	// for more info, see https://man7.org/linux/man-pages/man3/errno.3.html
-	_, out, err := runWithOutput("rc-service", s.Name, "status")
+	_, out, err := runWithOutput("rc-service", s.Config.Name, "status")
 	if err != nil {
 		if exiterr, ok := err.(*exec.ExitError); ok {
 			// The program has exited with an exit code != 0
@@ -194,12 +324,16 @@ func (s *openrc) Status() (Status, error) {
 	return StatusRunning, nil
 }
 
+func (s *openrc) Installed() (bool, error) {
+	return installed(s)
+}
+
 func (s *openrc) Start() error {
-	return run("rc-service", s.Name, "start")
+	return run("rc-service", s.Config.Name, "start")
 }
 
 func (s *openrc) Stop() error {
-	return run("rc-service", s.Name, "stop")
+	return run("rc-service", s.Config.Name, "stop")
 }
 
 func (s *openrc) Restart() error {
@@ -212,7 +346,7 @@ func (s *openrc) Restart() error {
 }
 
 func (s *openrc) runAction(action string) error {
-	return s.run(action, s.Name)
+	return s.run(action, s.Config.Name)
 }
 
 func (s *openrc) run(action string, args ...string) error {
@@ -224,6 +358,9 @@ supervisor=supervise-daemon
 name="{{.DisplayName}}"
 description="{{.Description}}"
 command={{.Path|cmdEscape}}
+{{- if .UserName }}
+command_user={{.UserName|shellQuote}}
+{{- end }}
 {{- if .Arguments }}
 command_args="{{range .Arguments}}{{.}} {{end}}"
 {{- end }}
@@ -234,9 +371,9 @@ supervise_daemon_args="--stdout {{.LogDirectory}}/${name}.log --stderr {{.LogDir
 export {{$k}}={{$v}}
 {{end -}}
 
-{{- if .Dependencies }}
+{{- if .Depend }}
 depend() {
-{{- range $i, $dep := .Dependencies}} 
+{{- range $i, $dep := .Depend}}
 {{"\t"}}{{$dep}}{{end}}
 }
 {{- end}}
@@ -0,0 +1,64 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenRCDepend(t *testing.T) {
+	tests := []struct {
+		name string
+		deps []string
+		want []string
+	}{
+		{"empty", nil, []string{}},
+		{"need", []string{"Requires=net"}, []string{"need net"}},
+		{"use", []string{"Wants=logger dns"}, []string{"use logger dns"}},
+		{"after", []string{"After=network.service syslog"}, []string{"after network syslog"}},
+		{"before", []string{"Before=nginx"}, []string{"before nginx"}},
+		{"binds to", []string{"BindsTo=partner.service"}, []string{"need partner"}},
+		{"requisite", []string{"Requisite=db"}, []string{}},
+		{"native", []string{"need localmount", "keyword -docker"}, []string{"need localmount", "keyword -docker"}},
+		{"blank", []string{"", "  ", "After="}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := openRCDepend(tt.deps)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("openRCDepend() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenRCUser(t *testing.T) {
+	s := &openrc{Config: &Config{Name: "test", UserName: "app user", Option: KeyValue{}}}
+	to := &struct {
+		*Config
+		Path         string
+		LogDirectory string
+		Depend       []string
+	}{s.Config, "/usr/bin/app", defaultLogDirectory, nil}
+	var b bytes.Buffer
+	if err := s.template().Execute(&b, to); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\ncommand_user='app user'\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("script does not contain %q:\n%s", want, b.String())
+	}
+
+	s.UserName = ""
+	b.Reset()
+	if err := s.template().Execute(&b, to); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "command_user") {
+		t.Errorf("script without UserName sets command_user:\n%s", b.String())
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux && !darwin && !solaris && !aix && !freebsd && !windows
+// +build !linux,!darwin,!solaris,!aix,!freebsd,!windows
+
+package service
+
+// No service system is implemented on these systems, such as OpenBSD,
+// NetBSD and DragonFly BSD. These are the platform hooks the rest of the
+// package uses, New returns ErrNoServiceSystemDetected before they matter.
+
+// directoryRoots is empty, the RuntimeDirectory, StateDirectory and
+// LogsDirectory options are not supported.
+var directoryRoots = map[string]string{}
+
+// privilegeHint is added to permission errors from Install.
+const privilegeHint = "run as root"
+
+func canManage() (bool, error) {
+	return false, ErrNotSupported
+}
+
+func relaunchElevated() (bool, error) {
+	return false, ErrNotSupported
+}
+
+// chown does nothing, directory owners are not supported.
+func chown(path, userName, groupName string) error {
+	return nil
+}
+
+// watchReload does nothing, RestartOnReload is not supported.
+func watchReload(i Interface, s Service, kv KeyValue) func() {
+	return func() {}
+}
@@ -66,7 +66,11 @@ func (s *rcs) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *rcs) Name() string {
+	return s.Config.Name
 }
 
 func (s *rcs) Platform() string {
@@ -85,6 +89,10 @@ func (s *rcs) configPath() (cp string, err error) {
 	return
 }
 
+func (s *rcs) location() (string, error) {
+	return s.configPath()
+}
+
 func (s *rcs) template() *template.Template {
 	customScript := s.Option.string(optionRCSScript, "")
 
@@ -95,6 +103,10 @@ func (s *rcs) template() *template.Template {
 }
 
 func (s *rcs) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *rcs) install() error {
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -106,7 +118,7 @@ func (s *rcs) Install() error {
 
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
@@ -134,14 +146,17 @@ func (s *rcs) Install() error {
 		return err
 	}
 
-	if err = os.Symlink(confPath, "/etc/rc.d/S50"+s.Name); err != nil {
-		return err
+	if !s.startAtBoot() {
+		return nil
 	}
-
-	return nil
+	return s.Enable()
 }
 
 func (s *rcs) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *rcs) uninstall() error {
 	cp, err := s.configPath()
 	if err != nil {
 		return err
@@ -149,39 +164,74 @@ func (s *rcs) Uninstall() error {
 	if err := os.Remove(cp); err != nil {
 		return err
 	}
-	if err := os.Remove("/etc/rc.d/S50" + s.Name); err != nil {
+	return s.Disable()
+}
+
+func (s *rcs) Enable() error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	if err = os.Symlink(confPath, "/etc/rc.d/S50"+s.Config.Name); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *rcs) Disable() error {
+	if err := os.Remove("/etc/rc.d/S50" + s.Config.Name); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
 }
 
 func (s *rcs) Logger(errs chan<- error) (Logger, error) {
-	if system.Interactive() {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 func (s *rcs) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 func (s *rcs) Run() (err error) {
+	if err = runInit(s.Option); err != nil {
+		return err
+	}
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	if err = setNice(s.Option); err != nil {
+		return err
+	}
+
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *rcs) Status() (Status, error) {
-	_, out, err := runWithOutput("/etc/init.d/"+s.Name, "status")
+	_, out, err := runWithOutput("/etc/init.d/"+s.Config.Name, "status")
 	if err != nil {
 		return StatusUnknown, err
 	}
@@ -196,12 +246,32 @@ func (s *rcs) Status() (Status, error) {
 	}
 }
 
+func (s *rcs) Installed() (bool, error) {
+	return installed(s)
+}
+
+func (s *rcs) Usage() (ResourceUsage, error) {
+	pid, err := pidFromFile("/var/run/" + s.Config.Name + ".pid")
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	return processUsage(pid)
+}
+
+func (s *rcs) Kill() error {
+	pid, err := pidFromFile("/var/run/" + s.Config.Name + ".pid")
+	if err != nil {
+		return err
+	}
+	return killProcess(pid)
+}
+
 func (s *rcs) Start() error {
-	return run("/etc/init.d/"+s.Name, "start")
+	return run("/etc/init.d/"+s.Config.Name, "start")
 }
 
 func (s *rcs) Stop() error {
-	return run("/etc/init.d/"+s.Name, "stop")
+	return run("/etc/init.d/"+s.Config.Name, "stop")
 }
 
 func (s *rcs) Restart() error {
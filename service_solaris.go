@@ -72,7 +72,11 @@ func (s *solarisService) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *solarisService) Name() string {
+	return s.Config.Name
 }
 
 func (s *solarisService) Platform() string {
@@ -102,11 +106,19 @@ func (s *solarisService) configPath() (string, error) {
 	return "/lib/svc/manifest/" + s.Prefix + "/" + s.Config.Name + ".xml", nil
 }
 
+func (s *solarisService) location() (string, error) {
+	return s.configPath()
+}
+
 func (s *solarisService) getFMRI() string {
 	return "svc:/" + s.Prefix + "/" + s.Config.Name + ":default"
 }
 
 func (s *solarisService) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *solarisService) install() error {
 	// write start script
 	confPath, err := s.configPath()
 	if err != nil {
@@ -119,7 +131,7 @@ func (s *solarisService) Install() error {
 
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
@@ -159,6 +171,10 @@ func (s *solarisService) Install() error {
 }
 
 func (s *solarisService) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *solarisService) uninstall() error {
 	s.Stop()
 
 	confPath, err := s.configPath()
@@ -199,6 +215,10 @@ func (s *solarisService) Status() (Status, error) {
 	return StatusUnknown, err
 }
 
+func (s *solarisService) Installed() (bool, error) {
+	return installed(s)
+}
+
 func (s *solarisService) Start() error {
 	return run("/usr/sbin/svcadm", "enable", s.getFMRI())
 }
@@ -215,30 +235,42 @@ func (s *solarisService) Restart() error {
 }
 
 func (s *solarisService) Run() error {
-	var err error
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
 
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *solarisService) Logger(errs chan<- error) (Logger, error) {
-	if interactive {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 func (s *solarisService) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 var manifest = `<?xml version="1.0"?>
@@ -27,6 +27,7 @@ const runAsServiceArg = "RunThisAsService"
 func TestMain(m *testing.M) {
 	reportDir := flag.String("su.reportDir", "", "")
 	runAsService := flag.Bool("su.runAsService", false, "")
+	serviceName := flag.String("su.serviceName", "go_service_test", "")
 	flag.Parse()
 	if !*runAsService {
 		os.Exit(m.Run())
@@ -35,7 +36,7 @@ func TestMain(m *testing.M) {
 		log.Fatal("missing su.reportDir argument")
 	}
 	writeReport(*reportDir, "call")
-	runService()
+	runService(*serviceName)
 	writeReport(*reportDir, "finished")
 }
 
@@ -45,12 +46,10 @@ func TestInstallRunRestartStopRemove(t *testing.T) {
 	defer os.RemoveAll(reportDir)
 
 	s := mustNewRunAsService(t, p, reportDir)
-	_ = s.Uninstall()
-
 	if err := s.Install(); err != nil {
 		t.Fatal("Install", err)
 	}
-	defer s.Uninstall()
+	defer service.ForceUninstall(s)
 
 	if err := s.Start(); err != nil {
 		t.Fatal("Start", err)
@@ -74,10 +73,10 @@ func TestInstallRunRestartStopRemove(t *testing.T) {
 	}
 }
 
-func runService() {
+func runService(name string) {
 	p := &program{}
 	sc := &service.Config{
-		Name: "go_service_test",
+		Name: name,
 	}
 	s, err := service.New(p, sc)
 	if err != nil {
@@ -169,10 +168,11 @@ func mustNewRunAsService(
 	p *program,
 	reportDir string,
 ) service.Service {
-	sc := &service.Config{
-		Name:      "go_service_test",
-		Arguments: []string{"-test.v=true", "-su.runAsService", "-su.reportDir", reportDir},
+	sc, err := service.UniqueConfig("go_service_test")
+	if err != nil {
+		t.Fatal(err)
 	}
+	sc.Arguments = []string{"-test.v=true", "-su.runAsService", "-su.reportDir", reportDir, "-su.serviceName", sc.Name}
 	s, err := service.New(p, sc)
 	if err != nil {
 		t.Fatal(err)
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -17,6 +18,7 @@ import (
 	"strings"
 	"syscall"
 	"text/template"
+	"time"
 )
 
 func isSystemd() bool {
@@ -64,7 +66,11 @@ func (s *systemd) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *systemd) Name() string {
+	return s.Config.Name
 }
 
 func (s *systemd) Platform() string {
@@ -89,6 +95,40 @@ func (s *systemd) configPath() (cp string, err error) {
 	return
 }
 
+// dropInPath returns the path of the override drop-in of the unit.
+func (s *systemd) dropInPath() (string, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cp+".d", "override.conf"), nil
+}
+
+func (s *systemd) location() (string, error) {
+	if s.isDropIn() {
+		return s.dropInPath()
+	}
+	return s.configPath()
+}
+
+// validateName checks the unit name has only the characters systemd allows,
+// and fits in the 255 characters of a unit name.
+func (s *systemd) validateName() error {
+	for _, r := range s.Config.Name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(":-_.@\\", r)) {
+			return fmt.Errorf("%w %q: systemd does not allow %q", ErrInvalidName, s.Config.Name, r)
+		}
+	}
+	if len(s.unitName()) > 255 {
+		return fmt.Errorf("%w %q: longer than the 255 characters of a systemd unit name", ErrInvalidName, s.Config.Name)
+	}
+	return nil
+}
+
+func (s *systemd) isDropIn() bool {
+	return s.Option.bool(optionDropIn, optionDropInDefault)
+}
+
 func (s *systemd) unitName() string {
 	return s.Config.Name + ".service"
 }
@@ -127,6 +167,148 @@ func (s *systemd) hasOutputFileSupport() bool {
 	return defaultValue
 }
 
+// systemdArgReplacer escapes a double quoted ExecStart argument: backslashes
+// and quotes for the command line parser, % for specifiers and $ for
+// environment variable substitution.
+var systemdArgReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, `%`, `%%`, `$`, `$$`)
+
+// systemdArg quotes an ExecStart argument so systemd passes it unchanged.
+func systemdArg(s string) string {
+	return `"` + systemdArgReplacer.Replace(s) + `"`
+}
+
+// systemdPathReplacer escapes an unquoted executable path, which is not
+// subject to environment variable substitution.
+var systemdPathReplacer = strings.NewReplacer(`\`, `\\`, " ", `\x20`, "\t", `\t`, `"`, `\"`, `'`, `\'`, `%`, `%%`)
+
+// systemdPath escapes the executable path of ExecStart.
+func systemdPath(s string) string {
+	return systemdPathReplacer.Replace(s)
+}
+
+// timeouts returns TimeoutStartSec and TimeoutStopSec, the latter defaulting
+// to the StopTimeout option.
+func (s *systemd) timeouts() (start, stop string, err error) {
+	if start, err = systemdTimeout(s.Option.string(optionTimeoutStartSec, "")); err != nil {
+		return "", "", err
+	}
+	stop, err = systemdTimeout(s.Option.string(optionTimeoutStopSec, s.Option.string(optionStopTimeout, "")))
+	return start, stop, err
+}
+
+// kill returns the KillMode and KillSignal options, checking the mode is one
+// systemd knows and the signal is a single word.
+func (s *systemd) kill() (mode, sig string, err error) {
+	mode = s.Option.string(optionKillMode, "")
+	switch mode {
+	case "", "control-group", "mixed", "process", "none":
+	default:
+		return "", "", fmt.Errorf("invalid KillMode %q", mode)
+	}
+	sig = s.Option.string(optionKillSignal, "")
+	if strings.ContainsAny(sig, " \t\r\n") {
+		return "", "", fmt.Errorf("invalid KillSignal %q", sig)
+	}
+	return mode, sig, nil
+}
+
+// ioScheduling returns the IOSchedulingClass and IOSchedulingPriority
+// options, checking they are ones systemd knows. Unset options are empty.
+func (s *systemd) ioScheduling() (string, string, error) {
+	class := s.Option.string(optionIOSchedulingClass, "")
+	switch class {
+	case "", "realtime", "best-effort", "idle":
+	default:
+		return "", "", fmt.Errorf("invalid IOSchedulingClass %q", class)
+	}
+	if _, ok := s.Option[optionIOSchedulingPriority]; !ok {
+		return class, "", nil
+	}
+	priority := s.Option.int(optionIOSchedulingPriority, 0)
+	if priority < 0 || priority > 7 {
+		return "", "", fmt.Errorf("IOSchedulingPriority %d is not within 0..7", priority)
+	}
+	return class, strconv.Itoa(priority), nil
+}
+
+// cpuScheduling returns the CPUSchedulingPolicy and CPUSchedulingPriority
+// options, checking they are ones systemd knows. Unset options are empty.
+func (s *systemd) cpuScheduling() (string, string, error) {
+	policy := s.Option.string(optionCPUSchedulingPolicy, "")
+	switch policy {
+	case "", "other", "batch", "idle", "fifo", "rr":
+	default:
+		return "", "", fmt.Errorf("invalid CPUSchedulingPolicy %q", policy)
+	}
+	if _, ok := s.Option[optionCPUSchedulingPriority]; !ok {
+		return policy, "", nil
+	}
+	if policy != "fifo" && policy != "rr" {
+		return "", "", errors.New("CPUSchedulingPriority needs CPUSchedulingPolicy fifo or rr")
+	}
+	priority := s.Option.int(optionCPUSchedulingPriority, 0)
+	if priority < 1 || priority > 99 {
+		return "", "", fmt.Errorf("CPUSchedulingPriority %d is not within 1..99", priority)
+	}
+	return policy, strconv.Itoa(priority), nil
+}
+
+// conditions returns the Conditions option, checking each is a Condition or
+// Assert directive on a line of its own.
+func (s *systemd) conditions() ([]string, error) {
+	conditions := s.Option.strings(optionConditions)
+	for _, c := range conditions {
+		name, _, ok := strings.Cut(c, "=")
+		if !ok || !(strings.HasPrefix(name, "Condition") || strings.HasPrefix(name, "Assert")) ||
+			strings.ContainsAny(c, "\r\n") {
+			return nil, fmt.Errorf("invalid condition %q", c)
+		}
+	}
+	return conditions, nil
+}
+
+// unitFileMode returns the mode of the unit or drop-in, only readable by root
+// when it holds the secrets of the SetCredential option.
+func (s *systemd) unitFileMode() os.FileMode {
+	if len(s.Option.strings(optionSetCredential)) > 0 {
+		return 0600
+	}
+	return 0644
+}
+
+// credentials returns the LoadCredential and SetCredential options,
+// checking each is an "ID:..." pair on a line of its own.
+func (s *systemd) credentials() ([]string, []string, error) {
+	load, set := s.Option.strings(optionLoadCredential), s.Option.strings(optionSetCredential)
+	for _, c := range append(append([]string{}, load...), set...) {
+		if i := strings.Index(c, ":"); i <= 0 || strings.ContainsAny(c, "\r\n") {
+			return nil, nil, fmt.Errorf("invalid credential %q, want ID:...", c)
+		}
+	}
+	return load, set, nil
+}
+
+// systemdTimeout converts a time.Duration string or "infinity" to a systemd
+// time span. The empty string is returned as is.
+func systemdTimeout(v string) (string, error) {
+	if v == "" || v == "infinity" {
+		return v, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return "", fmt.Errorf("invalid timeout %q: %v", v, err)
+	}
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second), nil
+	}
+	return fmt.Sprintf("%dms", d/time.Millisecond), nil
+}
+
+// systemdValue doubles % so systemd does not expand it as a specifier.
+func systemdValue(s string) string {
+	return strings.Replace(s, "%", "%%", -1)
+}
+
 func (s *systemd) template() *template.Template {
 	customScript := s.Option.string(optionSystemdScript, "")
 
@@ -140,65 +322,560 @@ func (s *systemd) isUserService() bool {
 	return s.Option.bool(optionUserService, optionUserServiceDefault)
 }
 
+// Install writes the unit file, enables it and reloads systemd. Like on
+// Windows an existing unit is an error, unless the Overwrite option is set
+// in which case the unit file is rewritten.
+//
+// With the DropIn option the unit must already be installed, only the
+// override drop-in is written.
 func (s *systemd) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *systemd) install() error {
+	if s.isDropIn() {
+		return s.installDropIn()
+	}
+
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
 	}
 	_, err = os.Stat(confPath)
-	if err == nil {
+	if err == nil && !s.Option.bool(optionOverwrite, optionOverwriteDefault) {
 		return fmt.Errorf("Init already exists: %s", confPath)
 	}
 
-	f, err := os.OpenFile(confPath, os.O_WRONLY|os.O_CREATE, 0644)
+	f, err := os.OpenFile(confPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, s.unitFileMode())
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
+	// OpenFile leaves the mode of an overwritten unit.
+	if err = f.Chmod(s.unitFileMode()); err != nil {
+		return privilegeError(err)
+	}
+
+	err = s.writeUnit(f)
+	if err != nil {
+		return err
+	}
+
+	err = s.installSlice()
+	if err != nil {
+		return err
+	}
+
+	err = s.installTarget()
+	if err != nil {
+		return err
+	}
+
+	if s.startAtBoot() {
+		err = s.runAction("enable")
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.daemonReload()
+}
 
+// writeUnit writes the unit file for the service to w.
+func (s *systemd) writeUnit(w io.Writer) error {
 	path, err := s.execPath()
 	if err != nil {
 		return err
 	}
+	timeoutStart, timeoutStop, err := s.timeouts()
+	if err != nil {
+		return err
+	}
+	killMode, killSignal, err := s.kill()
+	if err != nil {
+		return err
+	}
+	niceValue, err := s.nice()
+	if err != nil {
+		return err
+	}
+	ioClass, ioPriority, err := s.ioScheduling()
+	if err != nil {
+		return err
+	}
+	cpuPolicy, cpuPriority, err := s.cpuScheduling()
+	if err != nil {
+		return err
+	}
+	conditions, err := s.conditions()
+	if err != nil {
+		return err
+	}
+	loadCredential, setCredential, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	wantedBy, requiredBy, err := s.installTargets()
+	if err != nil {
+		return err
+	}
+	notifyReload, err := s.notifyReload()
+	if err != nil {
+		return err
+	}
 
 	var to = &struct {
 		*Config
-		Path                 string
-		HasOutputFileSupport bool
-		ReloadSignal         string
-		PIDFile              string
-		LimitNOFILE          int
-		Restart              string
-		SuccessExitStatus    string
-		LogOutput            bool
-		LogDirectory         string
+		Path                     string
+		HasOutputFileSupport     bool
+		ReloadSignal             string
+		PIDFile                  string
+		LimitNOFILE              int
+		Restart                  string
+		SuccessExitStatus        string
+		RestartPreventExitStatus string
+		LogOutput                bool
+		LogDirectory             string
+		Slice                    string
+		DirectoryDirectives      map[string]string
+		ExecStartPre             []string
+		ExecStartPost            []string
+		TimeoutStartSec          string
+		TimeoutStopSec           string
+		KillMode                 string
+		KillSignal               string
+		PartOf                   []string
+		Target                   string
+		Nice                     string
+		IOSchedulingClass        string
+		IOSchedulingPriority     string
+		CPUSchedulingPolicy      string
+		CPUSchedulingPriority    string
+		Conditions               []string
+		LoadCredential           []string
+		SetCredential            []string
+		WantedBy                 []string
+		RequiredBy               []string
+		DefaultDependencies      bool
+		NotifyReload             string
 	}{
 		s.Config,
 		path,
 		s.hasOutputFileSupport(),
-		s.Option.string(optionReloadSignal, ""),
+		reloadSignal(s.Option),
 		s.Option.string(optionPIDFile, ""),
 		s.Option.int(optionLimitNOFILE, optionLimitNOFILEDefault),
 		s.Option.string(optionRestart, "always"),
 		s.Option.string(optionSuccessExitStatus, ""),
+		s.Option.string(optionRestartPreventExitStatus, ""),
 		s.Option.bool(optionLogOutput, optionLogOutputDefault),
 		s.Option.string(optionLogDirectory, defaultLogDirectory),
+		s.slice(),
+		s.directoryDirectives(),
+		s.Option.strings(optionExecStartPre),
+		s.Option.strings(optionExecStartPost),
+		timeoutStart,
+		timeoutStop,
+		killMode,
+		killSignal,
+		s.partOf(),
+		s.target(),
+		niceValue,
+		ioClass,
+		ioPriority,
+		cpuPolicy,
+		cpuPriority,
+		conditions,
+		loadCredential,
+		setCredential,
+		wantedBy,
+		requiredBy,
+		s.Option.bool(optionDefaultDependencies, optionDefaultDependenciesDefault),
+		notifyReload,
+	}
+	for _, command := range append(to.ExecStartPre, to.ExecStartPost...) {
+		if strings.ContainsAny(command, "\r\n") {
+			return fmt.Errorf("command %q contains a line break", command)
+		}
+	}
+
+	return s.template().Execute(w, to)
+}
+
+// unitFileExists reports if systemd knows about the unit file, wherever it
+// was installed from.
+func (s *systemd) unitFileExists() (bool, error) {
+	exitCode, out, err := s.runWithOutput("systemctl", "list-unit-files", "-t", "service", s.unitName())
+	if exitCode == 0 && err != nil {
+		return false, err
 	}
+	return strings.Contains(out, s.unitName()), nil
+}
 
-	err = s.template().Execute(f, to)
+func (s *systemd) installDropIn() error {
+	exists, err := s.unitFileExists()
 	if err != nil {
 		return err
 	}
+	if !exists {
+		return fmt.Errorf("unit %s must be installed to use a drop-in", s.unitName())
+	}
 
-	err = s.runAction("enable")
+	dropInPath, err := s.dropInPath()
 	if err != nil {
 		return err
 	}
+	_, err = os.Stat(dropInPath)
+	if err == nil && !s.Option.bool(optionOverwrite, optionOverwriteDefault) {
+		return fmt.Errorf("Drop-in already exists: %s", dropInPath)
+	}
+	if err = os.MkdirAll(filepath.Dir(dropInPath), 0755); err != nil {
+		return err
+	}
 
-	return s.run("daemon-reload")
+	f, err := os.OpenFile(dropInPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, s.unitFileMode())
+	if err != nil {
+		return privilegeError(err)
+	}
+	defer f.Close()
+	if err = f.Chmod(s.unitFileMode()); err != nil {
+		return privilegeError(err)
+	}
+
+	err = s.writeDropIn(f)
+	if err != nil {
+		return err
+	}
+
+	err = s.installSlice()
+	if err != nil {
+		return err
+	}
+
+	err = s.daemonReload()
+	if err != nil {
+		return err
+	}
+
+	if !s.startAtBoot() {
+		return nil
+	}
+	return s.runAction("enable")
+}
+
+// slice returns the name of the slice unit from the Slice option.
+func (s *systemd) slice() string {
+	slice := s.Option.string(optionSlice, "")
+	if slice != "" && !strings.HasSuffix(slice, ".slice") {
+		slice += ".slice"
+	}
+	return slice
+}
+
+// nice returns the Nice directive value, empty when the option is not set.
+func (s *systemd) nice() (string, error) {
+	n, ok, err := nice(s.Option)
+	if err != nil || !ok {
+		return "", err
+	}
+	return strconv.Itoa(n), nil
+}
+
+// target returns the Target option with the .target suffix.
+func (s *systemd) target() string {
+	target := s.Option.string(optionTarget, "")
+	if target != "" && !strings.HasSuffix(target, ".target") {
+		target += ".target"
+	}
+	return target
+}
+
+// partOf returns the PartOf option and the target of the service.
+func (s *systemd) partOf() []string {
+	partOf := s.Option.strings(optionPartOf)
+	if target := s.target(); target != "" {
+		partOf = append(partOf, target)
+	}
+	return partOf
+}
+
+// directoryDirectives returns the RuntimeDirectory, StateDirectory and
+// LogsDirectory directives, and their modes, that are set in the options.
+func (s *systemd) directoryDirectives() map[string]string {
+	directives := make(map[string]string)
+	for _, o := range directoryOptions {
+		if v := s.Option.string(o.name, ""); v != "" {
+			directives[o.name] = v
+		}
+		if v := s.Option.string(o.mode, ""); v != "" {
+			directives[o.mode] = v
+		}
+	}
+	return directives
+}
+
+// managesDirectories reports that systemd creates the directories of the
+// RuntimeDirectory, StateDirectory and LogsDirectory options.
+func (s *systemd) managesDirectories() bool {
+	return true
+}
+
+// installSlice writes the slice unit from the SliceConfig option next to
+// the service unit. An existing slice unit may be shared with other
+// services, so it is kept unless Overwrite is set, and never removed by
+// Uninstall.
+func (s *systemd) installSlice() error {
+	slice, sliceConfig := s.slice(), s.Option.string(optionSliceConfig, "")
+	if slice == "" || sliceConfig == "" {
+		return nil
+	}
+	cp, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	slicePath := filepath.Join(filepath.Dir(cp), slice)
+	if _, err = os.Stat(slicePath); err == nil && !s.Option.bool(optionOverwrite, optionOverwriteDefault) {
+		return nil
+	}
+	err = os.WriteFile(slicePath, []byte(fmt.Sprintf(systemdSlice, slice, sliceConfig)), 0644)
+	return privilegeError(err)
+}
+
+// notifyReload returns the ReloadSignal= of a Type=notify-reload unit with
+// the NotifyReload option, or the empty string. RestartOnReload must handle
+// the signal, it would end the service otherwise.
+func (s *systemd) notifyReload() (string, error) {
+	if !s.Option.bool(optionNotifyReload, false) {
+		return "", nil
+	}
+	if !s.Option.bool(optionRestartOnReload, false) {
+		return "", errors.New("NotifyReload needs RestartOnReload to handle the reload signal")
+	}
+	return "SIG" + strings.TrimPrefix(strings.ToUpper(reloadSignal(s.Option)), "SIG"), nil
+}
+
+// installTargets returns the WantedBy and RequiredBy options, checking each
+// is on a line of its own. WantedBy defaults to the Target, or
+// multi-user.target when RequiredBy is not set either.
+func (s *systemd) installTargets() ([]string, []string, error) {
+	wantedBy, requiredBy := s.Option.strings(optionWantedBy), s.Option.strings(optionRequiredBy)
+	for _, unit := range append(append([]string{}, wantedBy...), requiredBy...) {
+		if strings.TrimSpace(unit) == "" || strings.ContainsAny(unit, "\r\n") {
+			return nil, nil, fmt.Errorf("invalid install target %q", unit)
+		}
+	}
+	if len(wantedBy) == 0 {
+		if target := s.target(); target != "" {
+			wantedBy = []string{target}
+		} else if len(requiredBy) == 0 {
+			wantedBy = []string{"multi-user.target"}
+		}
+	}
+	return wantedBy, requiredBy, nil
+}
+
+// installTarget writes the target unit of the Target option next to the
+// service unit, and enables it when the service starts at boot. Like the
+// slice unit it is shared with other services, so it is kept unless
+// Overwrite is set, and never removed by Uninstall.
+func (s *systemd) installTarget() error {
+	target := s.target()
+	if target == "" {
+		return nil
+	}
+	cp, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	targetPath := filepath.Join(filepath.Dir(cp), target)
+	if _, err = os.Stat(targetPath); err != nil || s.Option.bool(optionOverwrite, optionOverwriteDefault) {
+		err = os.WriteFile(targetPath, []byte(fmt.Sprintf(systemdTarget, target)), 0644)
+		if err != nil {
+			return privilegeError(err)
+		}
+	}
+	if !s.startAtBoot() {
+		return nil
+	}
+	return s.run("enable", target)
+}
+
+// updateExecutable rewrites the ExecStart of the installed unit or drop-in
+// from the Config, leaving the rest of the file as installed. A drop-in
+// without an ExecStart gets one overriding the command of the unit.
+func (s *systemd) updateExecutable() error {
+	path, err := s.location()
+	if err != nil {
+		return err
+	}
+	installed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err = s.writeUnit(&b); err != nil {
+		return err
+	}
+	updated, err := replaceExecStart(installed, b.Bytes(), s.isDropIn())
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// Renamed over the installed file so systemd never reads half of it.
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, updated, s.unitFileMode()); err != nil {
+		return privilegeError(err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return privilegeError(err)
+	}
+	return s.daemonReload()
+}
+
+// replaceExecStart replaces the ExecStart and ConditionFileIsExecutable lines
+// of installed with the ones of generated, so both name the new executable.
+// The empty ExecStart= resetting the command in a drop-in is kept, and with
+// dropIn a missing ExecStart is appended after such a reset.
+func replaceExecStart(installed, generated []byte, dropIn bool) ([]byte, error) {
+	const key, conditionKey = "ExecStart=", "ConditionFileIsExecutable="
+	var execStart, condition string
+	for _, line := range strings.Split(string(generated), "\n") {
+		if execStart == "" && strings.HasPrefix(line, key) && len(line) > len(key) {
+			execStart = line
+		}
+		if condition == "" && strings.HasPrefix(line, conditionKey) {
+			condition = line
+		}
+	}
+	if execStart == "" {
+		return nil, errors.New("no ExecStart generated")
+	}
+
+	lines := strings.Split(string(installed), "\n")
+	found := false
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, key) && len(line) > len(key):
+			lines[i] = execStart
+			found = true
+		case condition != "" && strings.HasPrefix(line, conditionKey) && len(line) > len(conditionKey):
+			lines[i] = condition
+		}
+	}
+	if !found && dropIn {
+		if n := len(lines); n > 0 && lines[n-1] == "" {
+			lines = lines[:n-1]
+		}
+		lines = append(lines, key, execStart, "")
+		found = true
+	}
+	if !found {
+		return nil, errors.New("no ExecStart installed")
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+// writeDropIn writes the override.conf drop-in for the service to w.
+func (s *systemd) writeDropIn(w io.Writer) error {
+	timeoutStart, timeoutStop, err := s.timeouts()
+	if err != nil {
+		return err
+	}
+	killMode, killSignal, err := s.kill()
+	if err != nil {
+		return err
+	}
+	niceValue, err := s.nice()
+	if err != nil {
+		return err
+	}
+	ioClass, ioPriority, err := s.ioScheduling()
+	if err != nil {
+		return err
+	}
+	cpuPolicy, cpuPriority, err := s.cpuScheduling()
+	if err != nil {
+		return err
+	}
+	loadCredential, setCredential, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	var to = &struct {
+		*Config
+		LimitNOFILE              int
+		Restart                  string
+		SuccessExitStatus        string
+		RestartPreventExitStatus string
+		TimeoutStartSec          string
+		TimeoutStopSec           string
+		KillMode                 string
+		KillSignal               string
+		Nice                     string
+		IOSchedulingClass        string
+		IOSchedulingPriority     string
+		CPUSchedulingPolicy      string
+		CPUSchedulingPriority    string
+		LoadCredential           []string
+		SetCredential            []string
+		Slice                    string
+		DirectoryDirectives      map[string]string
+	}{
+		s.Config,
+		s.Option.int(optionLimitNOFILE, optionLimitNOFILEDefault),
+		s.Option.string(optionRestart, ""),
+		s.Option.string(optionSuccessExitStatus, ""),
+		s.Option.string(optionRestartPreventExitStatus, ""),
+		timeoutStart,
+		timeoutStop,
+		killMode,
+		killSignal,
+		niceValue,
+		ioClass,
+		ioPriority,
+		cpuPolicy,
+		cpuPriority,
+		loadCredential,
+		setCredential,
+		s.slice(),
+		s.directoryDirectives(),
+	}
+
+	return template.Must(template.New("").Funcs(tf).Parse(systemdDropIn)).Execute(w, to)
+}
+
+func (s *systemd) uninstallDropIn() error {
+	err := s.runAction("disable")
+	if err != nil {
+		return err
+	}
+	dropInPath, err := s.dropInPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(dropInPath); err != nil {
+		return err
+	}
+	// Leave the directory if other drop-ins remain.
+	_ = os.Remove(filepath.Dir(dropInPath))
+	return s.daemonReload()
 }
 
 func (s *systemd) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *systemd) uninstall() error {
+	if s.isDropIn() {
+		return s.uninstallDropIn()
+	}
+
 	err := s.runAction("disable")
 	if err != nil {
 		return err
@@ -210,32 +887,290 @@ func (s *systemd) Uninstall() error {
 	if err := os.Remove(cp); err != nil {
 		return err
 	}
-	return s.run("daemon-reload")
+	return s.daemonReload()
+}
+
+// installedMatches compares the installed unit file, or drop-in, and
+// enabled state with what Install would write.
+func (s *systemd) installedMatches() (bool, error) {
+	var path string
+	var err error
+	write := s.writeUnit
+	if s.isDropIn() {
+		path, err = s.dropInPath()
+		write = s.writeDropIn
+	} else {
+		path, err = s.configPath()
+	}
+	if err != nil {
+		return false, err
+	}
+	installed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, ErrNotInstalled
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var want bytes.Buffer
+	if err := write(&want); err != nil {
+		return false, err
+	}
+	if !bytes.Equal(installed, want.Bytes()) {
+		return false, nil
+	}
+
+	_, out, err := s.runWithOutput("systemctl", "is-enabled", s.unitName())
+	if err != nil && out == "" {
+		return false, err
+	}
+	return (strings.TrimSpace(out) == "enabled") == s.startAtBoot(), nil
+}
+
+// installedSettings reads the settings of the unit and its drop-ins, the
+// last value of each, regardless of the section.
+func (s *systemd) installedSettings() (map[string]string, error) {
+	_, out, err := s.runWithOutput("systemctl", "cat", s.unitName())
+	if err != nil {
+		if exists, _ := s.unitFileExists(); !exists {
+			return nil, ErrNotInstalled
+		}
+		return nil, err
+	}
+	return unitSettings(out), nil
+}
+
+// unitSettings returns the last value of each setting of unit, the output
+// of "systemctl cat" with the unit followed by its drop-ins.
+func unitSettings(unit string) map[string]string {
+	settings := make(map[string]string)
+	for _, line := range unitLines(unit) {
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			// An empty value in a drop-in resets the earlier ones.
+			settings[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	return settings
+}
+
+// InstalledCommand reads the last ExecStart of the unit and its drop-ins.
+func (s *systemd) InstalledCommand() (string, []string, error) {
+	settings, err := s.installedSettings()
+	if err != nil {
+		return "", nil, err
+	}
+	return unitCommand(settings)
+}
+
+// unitCommand returns the executable and arguments of the ExecStart of
+// settings.
+func unitCommand(settings map[string]string) (string, []string, error) {
+	words, err := splitSystemdCommand(strings.TrimLeft(settings["ExecStart"], "-@:+!"))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(words) == 0 {
+		return "", nil, errors.New("the unit has no ExecStart")
+	}
+	return words[0], words[1:], nil
+}
+
+// installedConfig reads the description, command line, user, working
+// directory and start type of the unit.
+func (s *systemd) installedConfig() (*Config, error) {
+	settings, err := s.installedSettings()
+	if err != nil {
+		return nil, err
+	}
+	c, err := unitConfig(s.Config.Name, settings)
+	if err != nil {
+		return nil, err
+	}
+	startType, err := s.installedStartType()
+	if err != nil {
+		return nil, err
+	}
+	c.Option[StartType] = startType
+	return c, nil
+}
+
+// unitConfig returns the Config of the service name with the description,
+// command line, user and working directory of settings.
+func unitConfig(name string, settings map[string]string) (*Config, error) {
+	path, args, err := unitCommand(settings)
+	if err != nil {
+		return nil, err
+	}
+	c := &Config{
+		Name:        name,
+		Description: strings.Replace(settings["Description"], "%%", "%", -1),
+		UserName:    settings["User"],
+		Executable:  path,
+		Arguments:   args,
+		Option:      KeyValue{},
+	}
+	if dir, err := splitSystemdCommand(settings["WorkingDirectory"]); err == nil && len(dir) == 1 {
+		c.WorkingDirectory = dir[0]
+	}
+	return c, nil
+}
+
+// unitLines returns the lines of a unit file with the continuation lines,
+// ending in a backslash, joined.
+func unitLines(unit string) []string {
+	var lines []string
+	var line string
+	for _, l := range strings.Split(unit, "\n") {
+		l = strings.TrimSpace(l)
+		if strings.HasSuffix(l, "\\") {
+			line += strings.TrimSpace(strings.TrimSuffix(l, "\\")) + " "
+			continue
+		}
+		lines = append(lines, line+l)
+		line = ""
+	}
+	return lines
+}
+
+// splitSystemdCommand splits a command line the way systemd does, undoing
+// systemdArg and systemdPath: words are separated by white space, may be
+// quoted, and backslash escapes, %% and $$ are replaced.
+func splitSystemdCommand(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	var inWord bool
+	var quote rune
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+		switch {
+		case quote == 0 && (c == ' ' || c == '\t'):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == '\\':
+			if i+1 == len(runes) {
+				return nil, errors.New("command line ends in a backslash")
+			}
+			i++
+			switch runes[i] {
+			case 'n':
+				word.WriteByte('\n')
+			case 't':
+				word.WriteByte('\t')
+			case 'r':
+				word.WriteByte('\r')
+			case 'x':
+				if i+2 >= len(runes) {
+					return nil, errors.New("short \\x escape in command line")
+				}
+				b, err := strconv.ParseUint(string(runes[i+1:i+3]), 16, 8)
+				if err != nil {
+					return nil, fmt.Errorf("invalid \\x escape in command line: %v", err)
+				}
+				word.WriteByte(byte(b))
+				i += 2
+			default:
+				word.WriteRune(runes[i])
+			}
+		case (c == '%' || c == '$') && i+1 < len(runes) && runes[i+1] == c:
+			word.WriteRune(c)
+			i++
+		default:
+			word.WriteRune(c)
+		}
+		inWord = true
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote in command line")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func (s *systemd) installedStartType() (string, error) {
+	_, out, err := s.runWithOutput("systemctl", "is-enabled", s.unitName())
+	out = strings.TrimSpace(out)
+	if err != nil && out == "" {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(out, "enabled"):
+		return ServiceStartAutomatic, nil
+	case strings.HasPrefix(out, "masked"):
+		return ServiceStartDisabled, nil
+	default:
+		return ServiceStartManual, nil
+	}
+}
+
+func (s *systemd) Enable() error {
+	return s.runAction("enable")
+}
+
+func (s *systemd) Disable() error {
+	return s.runAction("disable")
 }
 
 func (s *systemd) Logger(errs chan<- error) (Logger, error) {
-	if system.Interactive() {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 func (s *systemd) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 func (s *systemd) Run() (err error) {
+	if err = runInit(s.Option); err != nil {
+		return err
+	}
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	if err := sdNotify("READY=1"); err != nil {
+		ConsoleLogger.Errorf("Failed to notify systemd of the start: %v", err)
+	}
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	if err := sdNotify("STOPPING=1"); err != nil {
+		ConsoleLogger.Errorf("Failed to notify systemd of the stop: %v", err)
+	}
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *systemd) Status() (Status, error) {
@@ -253,35 +1188,276 @@ func (s *systemd) Status() (Status, error) {
 		if exitCode == 0 && err != nil {
 			return StatusUnknown, err
 		}
-		if strings.Contains(out, s.Name) {
+		if strings.Contains(out, s.Config.Name) {
 			// unit file exists, installed but not running
 			return StatusStopped, nil
 		}
 		// no unit file
 		return StatusUnknown, ErrNotInstalled
-	case strings.HasPrefix(out, "activating"):
+	case strings.HasPrefix(out, "activating"), strings.HasPrefix(out, "deactivating"),
+		strings.HasPrefix(out, "reloading"), strings.HasPrefix(out, "refreshing"):
+		// The service is starting, stopping or reloading, see pending.
 		return StatusRunning, nil
 	case strings.HasPrefix(out, "failed"):
-		return StatusUnknown, errors.New("service in failed state")
+		return StatusFailed, nil
 	default:
 		return StatusUnknown, ErrNotInstalled
 	}
 }
 
+// pending reports if the unit is activating, deactivating or reloading.
+func (s *systemd) pending() (bool, error) {
+	state, err := s.UnitState()
+	if err != nil {
+		return false, err
+	}
+	switch state.ActiveState {
+	case "activating", "deactivating", "reloading", "refreshing":
+		return true, nil
+	}
+	return false, nil
+}
+
+// Installed checks the unit file exists. Unlike Status it doesn't depend on
+// the unit being in a state Status knows, such as while it is stopping.
+func (s *systemd) Installed() (bool, error) {
+	return s.unitFileExists()
+}
+
+func (s *systemd) UnitState() (UnitState, error) {
+	_, out, err := s.runWithOutput("systemctl", "show", "-p", "LoadState,ActiveState,SubState", s.unitName())
+	if err != nil {
+		return UnitState{}, err
+	}
+	var state UnitState
+	for _, line := range strings.Split(out, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		switch key {
+		case "LoadState":
+			if value == "not-found" {
+				return UnitState{}, ErrNotInstalled
+			}
+		case "ActiveState":
+			state.ActiveState = value
+		case "SubState":
+			state.SubState = value
+		}
+	}
+	return state, nil
+}
+
+func (s *systemd) StatusText() (string, error) {
+	_, out, err := s.runWithOutput("systemctl", "show", "-p", "StatusText", s.unitName())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(strings.TrimSpace(out), "StatusText="), nil
+}
+
+// pid returns the MainPID of the unit.
+func (s *systemd) pid() (int, error) {
+	_, out, err := s.runWithOutput("systemctl", "show", "-p", "MainPID", s.unitName())
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(out), "MainPID="))
+	if err != nil {
+		return 0, err
+	}
+	if pid == 0 {
+		return 0, ErrNotRunning
+	}
+	return pid, nil
+}
+
+func (s *systemd) Usage() (ResourceUsage, error) {
+	pid, err := s.pid()
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	return processUsage(pid)
+}
+
+// Kill sends SIGKILL to all the processes of the unit, in any active state
+// including deactivating, the state of a unit whose stop timed out.
+func (s *systemd) Kill() error {
+	state, err := s.UnitState()
+	if err != nil {
+		return err
+	}
+	if state.ActiveState == "inactive" || state.ActiveState == "failed" {
+		return ErrNotRunning
+	}
+	return s.run("kill", "-s", "SIGKILL", s.unitName())
+}
+
+// accepts reads CanStop of the unit, it is false when the unit refuses a
+// manual stop.
+func (s *systemd) accepts() (Accepted, error) {
+	status, err := s.Status()
+	if err != nil {
+		return 0, err
+	}
+	if status != StatusRunning {
+		return 0, ErrNotRunning
+	}
+	_, out, err := s.runWithOutput("systemctl", "show", "-p", "CanStop", s.unitName())
+	if err != nil {
+		return 0, err
+	}
+	accepted := AcceptShutdown
+	if strings.TrimSpace(out) == "CanStop=yes" {
+		accepted |= AcceptStop
+	}
+	return accepted, nil
+}
+
+// systemdStatuses queries the state of all the units with one systemctl call.
+func systemdStatuses(names []string) (map[string]Status, error) {
+	statuses := make(map[string]Status, len(names))
+	if len(names) == 0 {
+		return statuses, nil
+	}
+	units := make([]string, len(names))
+	for i, name := range names {
+		units[i] = name + ".service"
+	}
+
+	// is-active has a non-zero exit code unless all units are active.
+	_, out, err := runWithOutput("systemctl", append([]string{"is-active"}, units...)...)
+	states := strings.Fields(out)
+	if len(states) != len(units) {
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected systemctl is-active output: %q", out)
+	}
+
+	// inactive can also mean its not installed, check unit files
+	_, out, _ = runWithOutput("systemctl", append([]string{"list-unit-files", "-t", "service"}, units...)...)
+	installed := make(map[string]bool)
+	for _, line := range strings.Split(out, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			installed[fields[0]] = true
+		}
+	}
+
+	for i, name := range names {
+		switch states[i] {
+		case "active", "activating", "reloading":
+			statuses[name] = StatusRunning
+		case "inactive", "deactivating":
+			if installed[units[i]] {
+				statuses[name] = StatusStopped
+			} else {
+				statuses[name] = StatusUnknown
+			}
+		case "failed":
+			statuses[name] = StatusFailed
+		default:
+			statuses[name] = StatusUnknown
+		}
+	}
+	return statuses, nil
+}
+
+// systemdList returns the names of the installed system service units.
+func systemdList() ([]string, error) {
+	_, out, err := runWithOutput("systemctl", "list-unit-files", "-t", "service", "--no-legend", "--no-pager")
+	if err != nil {
+		return nil, err
+	}
+	return unitFileNames(out), nil
+}
+
+// unitFileNames returns the service names in systemctl list-unit-files
+// output, leaving out templates.
+func unitFileNames(out string) []string {
+	var names []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasSuffix(fields[0], ".service") {
+			continue
+		}
+		name := strings.TrimSuffix(fields[0], ".service")
+		if strings.HasSuffix(name, "@") {
+			continue
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// Start returns once the start job completed and the unit is active. For
+// services without sd_notify systemctl completes the job when the process
+// is started, so a service failing at once is reported as not running, one
+// failing later is not, see WaitHealthy.
 func (s *systemd) Start() error {
-	return s.runAction("start")
+	if err := s.runAction("start"); err != nil {
+		return err
+	}
+	return s.checkState("start")
 }
 
+// Stop returns once the stop job completed and the unit is inactive.
 func (s *systemd) Stop() error {
 	status, _ := s.Status()
 	if status != StatusRunning {
 		return nil
 	}
-	return s.runAction("stop")
+	if err := s.runAction("stop"); err != nil {
+		return err
+	}
+	return s.checkState("stop")
 }
 
+// Restart returns once the restart job completed and the unit is active,
+// as Start.
 func (s *systemd) Restart() error {
-	return s.runAction("restart")
+	if err := s.runAction("restart"); err != nil {
+		return err
+	}
+	return s.checkState("restart")
+}
+
+// DaemonReload makes systemd reload the unit files, such as once after
+// installing a batch of services with the DaemonReload option set to false.
+func (s *systemd) DaemonReload() error {
+	return s.run("daemon-reload")
+}
+
+// daemonReload reloads the unit files after a change, unless the
+// DaemonReload option defers it to the caller.
+func (s *systemd) daemonReload() error {
+	if !s.Option.bool(optionDaemonReload, optionDaemonReloadDefault) {
+		return nil
+	}
+	return s.DaemonReload()
+}
+
+// checkState checks the unit reached the state of the completed action.
+func (s *systemd) checkState(action string) error {
+	state, err := s.UnitState()
+	if err != nil {
+		return err
+	}
+	return unitStateError(s.unitName(), action, state)
+}
+
+// unitStateError returns an error when the unit is not active after a start
+// or restart, such as failed or waiting to be restarted after failing, or is
+// active again after a stop.
+func unitStateError(unit, action string, state UnitState) error {
+	active := state.ActiveState == "active" || state.ActiveState == "reloading" ||
+		state.ActiveState == "activating" && state.SubState != "auto-restart"
+	switch {
+	case action == "stop" && active:
+		return fmt.Errorf("%s is %s (%s) after stop", unit, state.ActiveState, state.SubState)
+	case action != "stop" && !active:
+		return fmt.Errorf("%w: %s is %s (%s) after %s, see journalctl -u %s",
+			ErrNotRunning, unit, state.ActiveState, state.SubState, action, unit)
+	}
+	return nil
 }
 
 func (s *systemd) runWithOutput(command string, arguments ...string) (int, string, error) {
@@ -303,34 +1479,119 @@ func (s *systemd) runAction(action string) error {
 }
 
 const systemdScript = `[Unit]
-Description={{.Description}}
-ConditionFileIsExecutable={{.Path|cmdEscape}}
+Description={{.Description|systemdValue}}
+ConditionFileIsExecutable={{.Path|cmdEscape|systemdValue}}
+{{if not .DefaultDependencies -}}
+DefaultDependencies=no
+{{end -}}
+{{range .PartOf -}}
+PartOf={{.}}
+{{end -}}
+{{range .Conditions -}}
+{{.}}
+{{end -}}
 {{range $i, $dep := .Dependencies}} 
 {{$dep}} {{end}}
 
 [Service]
 StartLimitInterval=5
 StartLimitBurst=10
-ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
-{{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
-{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
-{{if .UserName}}User={{.UserName}}{{end}}
-{{if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
-{{if .PIDFile}}PIDFile={{.PIDFile|cmd}}{{end}}
+{{range .ExecStartPre -}}
+ExecStartPre={{.}}
+{{end -}}
+ExecStart={{.Path|systemdPath}}{{range .Arguments}} {{.|systemdArg}}{{end}}
+{{range .ExecStartPost -}}
+ExecStartPost={{.}}
+{{end -}}
+{{if .ChRoot}}RootDirectory={{.ChRoot|cmd|systemdValue}}{{end}}
+{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape|systemdValue}}{{end}}
+{{if .UserName}}User={{.UserName|systemdValue}}{{end}}
+{{if .NotifyReload}}Type=notify-reload
+ReloadSignal={{.NotifyReload}}{{else if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
+{{if .PIDFile}}PIDFile={{.PIDFile|cmd|systemdValue}}{{end}}
 {{if and .LogOutput .HasOutputFileSupport -}}
-StandardOutput=file:{{.LogDirectory}}/{{.Name}}.out
-StandardError=file:{{.LogDirectory}}/{{.Name}}.err
+StandardOutput=file:{{.LogDirectory|systemdValue}}/{{.Name}}.out
+StandardError=file:{{.LogDirectory|systemdValue}}/{{.Name}}.err
 {{- end}}
 {{if gt .LimitNOFILE -1 }}LimitNOFILE={{.LimitNOFILE}}{{end}}
 {{if .Restart}}Restart={{.Restart}}{{end}}
 {{if .SuccessExitStatus}}SuccessExitStatus={{.SuccessExitStatus}}{{end}}
+{{if .RestartPreventExitStatus}}RestartPreventExitStatus={{.RestartPreventExitStatus}}{{end}}
+{{if .TimeoutStartSec}}TimeoutStartSec={{.TimeoutStartSec}}{{end}}
+{{if .TimeoutStopSec}}TimeoutStopSec={{.TimeoutStopSec}}{{end}}
+{{if .KillMode}}KillMode={{.KillMode}}{{end}}
+{{if .KillSignal}}KillSignal={{.KillSignal}}{{end}}
+{{if .Nice}}Nice={{.Nice}}{{end}}
+{{if .IOSchedulingClass}}IOSchedulingClass={{.IOSchedulingClass}}{{end}}
+{{if .IOSchedulingPriority}}IOSchedulingPriority={{.IOSchedulingPriority}}{{end}}
+{{if .CPUSchedulingPolicy}}CPUSchedulingPolicy={{.CPUSchedulingPolicy}}{{end}}
+{{if .CPUSchedulingPriority}}CPUSchedulingPriority={{.CPUSchedulingPriority}}{{end}}
+{{range .LoadCredential -}}
+LoadCredential={{.|systemdValue}}
+{{end -}}
+{{range .SetCredential -}}
+SetCredential={{.|systemdValue}}
+{{end -}}
+{{if .Slice}}Slice={{.Slice}}{{end}}
+{{range $k, $v := .DirectoryDirectives -}}
+{{$k}}={{$v|systemdValue}}
+{{end -}}
 RestartSec=120
 EnvironmentFile=-/etc/sysconfig/{{.Name}}
 
 {{range $k, $v := .EnvVars -}}
-Environment={{$k}}={{$v}}
+Environment={{$k|systemdValue}}={{$v|systemdValue}}
+{{end -}}
+
+[Install]
+{{range .WantedBy -}}
+WantedBy={{.}}
+{{end -}}
+{{range .RequiredBy -}}
+RequiredBy={{.}}
 {{end -}}
+`
+
+const systemdTarget = `[Unit]
+Description=%s
 
 [Install]
 WantedBy=multi-user.target
 `
+
+const systemdSlice = `[Unit]
+Description=%s
+
+[Slice]
+%s
+`
+
+const systemdDropIn = `[Service]
+{{if .UserName}}User={{.UserName|systemdValue}}{{end}}
+{{if gt .LimitNOFILE -1 }}LimitNOFILE={{.LimitNOFILE}}{{end}}
+{{if .Restart}}Restart={{.Restart}}{{end}}
+{{if .SuccessExitStatus}}SuccessExitStatus={{.SuccessExitStatus}}{{end}}
+{{if .RestartPreventExitStatus}}RestartPreventExitStatus={{.RestartPreventExitStatus}}{{end}}
+{{if .TimeoutStartSec}}TimeoutStartSec={{.TimeoutStartSec}}{{end}}
+{{if .TimeoutStopSec}}TimeoutStopSec={{.TimeoutStopSec}}{{end}}
+{{if .KillMode}}KillMode={{.KillMode}}{{end}}
+{{if .KillSignal}}KillSignal={{.KillSignal}}{{end}}
+{{if .Nice}}Nice={{.Nice}}{{end}}
+{{if .IOSchedulingClass}}IOSchedulingClass={{.IOSchedulingClass}}{{end}}
+{{if .IOSchedulingPriority}}IOSchedulingPriority={{.IOSchedulingPriority}}{{end}}
+{{if .CPUSchedulingPolicy}}CPUSchedulingPolicy={{.CPUSchedulingPolicy}}{{end}}
+{{if .CPUSchedulingPriority}}CPUSchedulingPriority={{.CPUSchedulingPriority}}{{end}}
+{{range .LoadCredential -}}
+LoadCredential={{.|systemdValue}}
+{{end -}}
+{{range .SetCredential -}}
+SetCredential={{.|systemdValue}}
+{{end -}}
+{{if .Slice}}Slice={{.Slice}}{{end}}
+{{range $k, $v := .DirectoryDirectives -}}
+{{$k}}={{$v|systemdValue}}
+{{end}}
+{{range $k, $v := .EnvVars -}}
+Environment={{$k|systemdValue}}={{$v|systemdValue}}
+{{end -}}
+`
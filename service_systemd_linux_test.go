@@ -0,0 +1,714 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSystemdArg(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  string
+		want string
+	}{
+		{"plain", "-v", `"-v"`},
+		{"space", "a b", `"a b"`},
+		{"quote", `say "hi"`, `"say \"hi\""`},
+		{"single quote", "it's", `"it's"`},
+		{"backslash", `C:\dir`, `"C:\\dir"`},
+		{"specifier", "100%", `"100%%"`},
+		{"variable", "$HOME", `"$$HOME"`},
+		{"newline", "a\nb", `"a\nb"`},
+		{"empty", "", `""`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := systemdArg(tt.arg); got != tt.want {
+				t.Errorf("systemdArg(%q) = %s, want %s", tt.arg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemdPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/usr/bin/app", "/usr/bin/app"},
+		{"/opt/my app/app", `/opt/my\x20app/app`},
+		{"/opt/100%/app", "/opt/100%%/app"},
+		{`/opt/a\b`, `/opt/a\\b`},
+		{`/opt/"a"`, `/opt/\"a\"`},
+	}
+	for _, tt := range tests {
+		if got := systemdPath(tt.path); got != tt.want {
+			t.Errorf("systemdPath(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSystemdExecStart(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/opt/my app/app",
+		Arguments:  []string{"run", "--name", "my service", "--format=%s"},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `ExecStart=/opt/my\x20app/app "run" "--name" "my service" "--format=%%s"`
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.HasPrefix(line, "ExecStart=") {
+			if line != want {
+				t.Errorf("got %s, want %s", line, want)
+			}
+			return
+		}
+	}
+	t.Errorf("no ExecStart in unit:\n%s", b.String())
+}
+
+func TestSystemdSpecifierEscape(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:             "test",
+		Description:      "100% uptime",
+		Executable:       "/usr/bin/app",
+		WorkingDirectory: "/srv/%h",
+		EnvVars:          map[string]string{"FORMAT": "%Y-%m-%d"},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	unit := b.String()
+	for _, want := range []string{
+		"Description=100%% uptime\n",
+		"WorkingDirectory=/srv/%%h\n",
+		"Environment=FORMAT=%%Y-%%m-%%d\n",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("unit does not contain %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestSystemdExecStartPrePost(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option: KeyValue{
+			"ExecStartPre":  []string{"-/usr/bin/mkdir -p /run/app", "+/usr/bin/setup"},
+			"ExecStartPost": "/usr/bin/notify up",
+		},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "StartLimitBurst=10\n" +
+		"ExecStartPre=-/usr/bin/mkdir -p /run/app\n" +
+		"ExecStartPre=+/usr/bin/setup\n" +
+		"ExecStart=/usr/bin/app\n" +
+		"ExecStartPost=/usr/bin/notify up\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("unit does not contain\n%s\ngot:\n%s", want, b.String())
+	}
+
+	s.Option["ExecStartPost"] = "/usr/bin/notify\nUser=root"
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with a line break in a command returned no error")
+	}
+}
+
+func TestSystemdTimeout(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"infinity", "infinity", false},
+		{"90s", "90s", false},
+		{"5m", "300s", false},
+		{"1.5s", "1500ms", false},
+		{"soon", "", true},
+	}
+	for _, tt := range tests {
+		got, err := systemdTimeout(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("systemdTimeout(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+
+	s := &systemd{Config: &Config{Name: "test", Option: KeyValue{"TimeoutStartSec": "infinity", "StopTimeout": "2m"}}}
+	start, stop, err := s.timeouts()
+	if err != nil || start != "infinity" || stop != "120s" {
+		t.Errorf("timeouts() = %q, %q, %v, want infinity, 120s", start, stop, err)
+	}
+}
+
+func TestSystemdKillMode(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option:     KeyValue{"KillMode": "mixed", "KillSignal": "SIGINT"},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\nKillMode=mixed\n", "\nKillSignal=SIGINT\n"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("unit does not contain %q:\n%s", want, b.String())
+		}
+	}
+
+	s.Option["KillMode"] = "all"
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with an invalid KillMode returned no error")
+	}
+
+	s.Option["KillMode"] = "mixed"
+	s.Option["KillSignal"] = "SIGINT\nExecStartPre=/bin/sh"
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with a KillSignal of two lines returned no error")
+	}
+	if err := s.writeDropIn(&b); err == nil {
+		t.Error("writeDropIn() with a KillSignal of two lines returned no error")
+	}
+}
+
+func TestSplitSystemdCommand(t *testing.T) {
+	path := "/opt/my app/100%/app"
+	args := []string{"run", "my service", `say "hi"`, `C:\dir`, "100%", "$HOME", "a\nb", ""}
+	line := systemdPath(path)
+	for _, arg := range args {
+		line += " " + systemdArg(arg)
+	}
+	words, err := splitSystemdCommand(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]string{path}, args...)
+	if !reflect.DeepEqual(words, want) {
+		t.Errorf("splitSystemdCommand(%s) = %q, want %q", line, words, want)
+	}
+
+	words, err = splitSystemdCommand(`/bin/app 'single quoted' unquoted\x20word`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/bin/app", "single quoted", "unquoted word"}; !reflect.DeepEqual(words, want) {
+		t.Errorf("got %q, want %q", words, want)
+	}
+
+	for _, bad := range []string{`/bin/app "open`, `/bin/app \`} {
+		if _, err := splitSystemdCommand(bad); err == nil {
+			t.Errorf("splitSystemdCommand(%s) returned no error", bad)
+		}
+	}
+}
+
+func TestUnitLines(t *testing.T) {
+	unit := "# /etc/systemd/system/app.service\n[Service]\nExecStart=/bin/app \\\n  --flag\nExecStart=\n"
+	want := []string{"# /etc/systemd/system/app.service", "[Service]", "ExecStart=/bin/app --flag", "ExecStart=", ""}
+	if got := unitLines(unit); !reflect.DeepEqual(got, want) {
+		t.Errorf("unitLines() = %q, want %q", got, want)
+	}
+}
+
+func TestUnitConfig(t *testing.T) {
+	const unit = "# /etc/systemd/system/app.service\n[Unit]\nDescription=My 100%% app\n\n" +
+		"[Service]\nExecStart=/usr/bin/app \"run\"\nUser=app\nWorkingDirectory=/var/lib/app\n"
+	tests := []struct {
+		name   string
+		dropIn string
+		want   *Config
+	}{
+		{
+			name: "unit",
+			want: &Config{Name: "app", Description: "My 100% app", UserName: "app", Executable: "/usr/bin/app",
+				Arguments: []string{"run"}, WorkingDirectory: "/var/lib/app", Option: KeyValue{}},
+		},
+		{
+			name:   "drop-in override",
+			dropIn: "[Service]\nExecStart=\nExecStart=-/opt/app/app \"serve\" \\\n  \"-v\"\nUser=daemon\n",
+			want: &Config{Name: "app", Description: "My 100% app", UserName: "daemon", Executable: "/opt/app/app",
+				Arguments: []string{"serve", "-v"}, WorkingDirectory: "/var/lib/app", Option: KeyValue{}},
+		},
+		{
+			name:   "drop-in reset",
+			dropIn: "[Unit]\nDescription=\n[Service]\nUser=\nWorkingDirectory=\n",
+			want: &Config{Name: "app", Executable: "/usr/bin/app", Arguments: []string{"run"},
+				Option: KeyValue{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := unit
+			if tt.dropIn != "" {
+				out += "\n# /etc/systemd/system/app.service.d/override.conf\n" + tt.dropIn
+			}
+			got, err := unitConfig("app", unitSettings(out))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unitConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	reset := unit + "\n# /etc/systemd/system/app.service.d/override.conf\n[Service]\nExecStart=\n"
+	if _, err := unitConfig("app", unitSettings(reset)); err == nil {
+		t.Error("unitConfig() of a unit with its ExecStart reset returned no error")
+	}
+}
+
+func Test_unitFileNames(t *testing.T) {
+	out := "cron.service                 enabled   enabled\n" +
+		"getty@.service               enabled   enabled\n" +
+		"myapp.service                disabled  enabled\n" +
+		"\n"
+	want := []string{"cron", "myapp"}
+	if got := unitFileNames(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("unitFileNames() = %q, want %q", got, want)
+	}
+}
+
+func TestSystemdTarget(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option: KeyValue{
+			"PartOf": "network.target",
+			"Target": "app",
+		},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"PartOf=network.target\nPartOf=app.target\n",
+		"[Install]\nWantedBy=app.target\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("unit does not contain\n%s\ngot:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestSystemdNice(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option:     KeyValue{"Nice": 10},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\nNice=10\n") {
+		t.Errorf("unit does not contain Nice=10, got:\n%s", b.String())
+	}
+
+	s.Option["Nice"] = 20
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with Nice 20 returned no error")
+	}
+}
+
+func TestSystemdRestartPreventExitStatus(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option:     KeyValue{"RestartPreventExitStatus": "3 SIGKILL"},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\nRestartPreventExitStatus=3 SIGKILL\n") {
+		t.Errorf("unit does not contain RestartPreventExitStatus=3 SIGKILL, got:\n%s", b.String())
+	}
+
+	b.Reset()
+	if err := s.writeDropIn(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "\nRestartPreventExitStatus=3 SIGKILL\n") {
+		t.Errorf("drop-in does not contain RestartPreventExitStatus=3 SIGKILL, got:\n%s", b.String())
+	}
+
+	delete(s.Option, "RestartPreventExitStatus")
+	b.Reset()
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "RestartPreventExitStatus") {
+		t.Errorf("unit without the option contains RestartPreventExitStatus:\n%s", b.String())
+	}
+}
+
+func TestSystemdIOScheduling(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option:     KeyValue{"IOSchedulingClass": "idle", "IOSchedulingPriority": 7},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "IOSchedulingClass=idle\nIOSchedulingPriority=7\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("unit does not contain\n%s\ngot:\n%s", want, b.String())
+	}
+
+	s.Option["IOSchedulingClass"] = "low"
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with IOSchedulingClass low returned no error")
+	}
+}
+
+func TestSystemdCPUScheduling(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option:     KeyValue{"CPUSchedulingPolicy": "fifo", "CPUSchedulingPriority": 50},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "CPUSchedulingPolicy=fifo\nCPUSchedulingPriority=50\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("unit does not contain\n%s\ngot:\n%s", want, b.String())
+	}
+
+	s.Option["CPUSchedulingPolicy"] = "batch"
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with a priority for policy batch returned no error")
+	}
+}
+
+func TestSystemdConditions(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option:     KeyValue{"Conditions": []string{"ConditionACPower=true", "AssertPathExists=/etc/app.conf"}},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "ConditionACPower=true\nAssertPathExists=/etc/app.conf\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("unit does not contain\n%s\ngot:\n%s", want, b.String())
+	}
+
+	for _, c := range []string{"ExecStart=/bin/sh", "ConditionACPower", "ConditionACPower=true\nUser=root"} {
+		s.Option["Conditions"] = c
+		if err := s.writeUnit(&b); err == nil {
+			t.Errorf("writeUnit() with condition %q returned no error", c)
+		}
+	}
+}
+
+func TestSystemdCredentials(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option: KeyValue{
+			"LoadCredential": "db:/etc/app/db",
+			"SetCredential":  []string{"token:100%"},
+		},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "LoadCredential=db:/etc/app/db\nSetCredential=token:100%%\n"
+	if !strings.Contains(b.String(), want) {
+		t.Errorf("unit does not contain\n%s\ngot:\n%s", want, b.String())
+	}
+	if got := s.unitFileMode(); got != 0600 {
+		t.Errorf("unitFileMode() with SetCredential = %v, want 0600", got)
+	}
+
+	s.Option["SetCredential"] = "token"
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with a credential without an id returned no error")
+	}
+
+	delete(s.Option, "SetCredential")
+	if got := s.unitFileMode(); got != 0644 {
+		t.Errorf("unitFileMode() without SetCredential = %v, want 0644", got)
+	}
+}
+
+func TestSystemdLocation(t *testing.T) {
+	s := &systemd{Config: &Config{Name: "test", Option: KeyValue{}}}
+	if got, err := Location(s); err != nil || got != "/etc/systemd/system/test.service" {
+		t.Errorf("Location() = %q, %v, want the unit file", got, err)
+	}
+	s.Option["DropIn"] = true
+	if got, err := Location(s); err != nil || got != "/etc/systemd/system/test.service.d/override.conf" {
+		t.Errorf("Location() of a drop-in = %q, %v, want the drop-in file", got, err)
+	}
+}
+
+func TestReplaceExecStart(t *testing.T) {
+	s := &systemd{Config: &Config{Name: "test", Executable: "/opt/app-2/app", Arguments: []string{"run"}, Option: KeyValue{}}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	installed := "[Unit]\nConditionFileIsExecutable=/opt/app-1/app\n\n" +
+		"[Service]\nExecStartPre=/bin/true\nExecStart=\nExecStart=/opt/app-1/app \"run\"\nRestart=always\n"
+	got, err := replaceExecStart([]byte(installed), b.Bytes(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[Unit]\nConditionFileIsExecutable=/opt/app-2/app\n\n" +
+		"[Service]\nExecStartPre=/bin/true\nExecStart=\nExecStart=/opt/app-2/app \"run\"\nRestart=always\n"
+	if string(got) != want {
+		t.Errorf("replaceExecStart() =\n%s\nwant:\n%s", got, want)
+	}
+
+	if _, err := replaceExecStart([]byte("[Service]\n"), b.Bytes(), false); err == nil {
+		t.Error("replaceExecStart() without an installed ExecStart returned no error")
+	}
+	got, err = replaceExecStart([]byte("[Service]\nRestart=always\n"), b.Bytes(), true)
+	if want := "[Service]\nRestart=always\nExecStart=\nExecStart=/opt/app-2/app \"run\"\n"; err != nil || string(got) != want {
+		t.Errorf("replaceExecStart() of a drop-in = %q, %v, want %q", got, err, want)
+	}
+}
+
+func TestSystemdValidateName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"app", true},
+		{"my-app_2.worker@1", true},
+		{"..", false},
+		{"dir/app", false},
+		{"app\n", false},
+		{"my app", false},
+		{"app$", false},
+		{strings.Repeat("a", 247), true},
+		{strings.Repeat("a", 248), false},
+	}
+	for _, tt := range tests {
+		s := &systemd{Config: &Config{Name: tt.name}}
+		err := validateName(s, tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("validateName(%q) error = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidName) {
+			t.Errorf("validateName(%q) error = %v, want %v", tt.name, err, ErrInvalidName)
+		}
+	}
+}
+
+func TestSystemdBindsTo(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:         "sidecar",
+		Executable:   "/usr/bin/sidecar",
+		Dependencies: []string{"BindsTo=app.service", "Requisite=db.service", "After=app.service db.service"},
+		Option:       KeyValue{},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	unit, _, _ := strings.Cut(b.String(), "[Service]")
+	for _, want := range []string{"\nBindsTo=app.service", "\nRequisite=db.service", "\nAfter=app.service db.service"} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("[Unit] does not contain %q, got:\n%s", want, unit)
+		}
+	}
+}
+
+func TestSystemdWantedBy(t *testing.T) {
+	tests := []struct {
+		option KeyValue
+		want   string
+	}{
+		{KeyValue{}, "[Install]\nWantedBy=multi-user.target\n"},
+		{KeyValue{"WantedBy": []string{"graphical.target", "sysinit.target"}}, "[Install]\nWantedBy=graphical.target\nWantedBy=sysinit.target\n"},
+		{KeyValue{"RequiredBy": "app.target"}, "[Install]\nRequiredBy=app.target\n"},
+		{KeyValue{"WantedBy": "graphical.target", "RequiredBy": "app.target"}, "[Install]\nWantedBy=graphical.target\nRequiredBy=app.target\n"},
+	}
+	for _, tt := range tests {
+		s := &systemd{Config: &Config{Name: "test", Executable: "/usr/bin/app", Option: tt.option}}
+		var b bytes.Buffer
+		if err := s.writeUnit(&b); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(b.String(), tt.want) {
+			t.Errorf("unit with %v does not end with\n%s\ngot:\n%s", tt.option, tt.want, b.String())
+		}
+	}
+
+	s := &systemd{Config: &Config{Name: "test", Executable: "/usr/bin/app", Option: KeyValue{"WantedBy": "a.target\nExecStart=/bin/sh"}}}
+	if err := s.writeUnit(&bytes.Buffer{}); err == nil {
+		t.Error("writeUnit() with a line break in WantedBy returned no error")
+	}
+}
+
+func TestSystemdDefaultDependencies(t *testing.T) {
+	s := &systemd{Config: &Config{Name: "test", Executable: "/usr/bin/app", Option: KeyValue{}}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "DefaultDependencies") {
+		t.Errorf("unit contains DefaultDependencies by default, got:\n%s", b.String())
+	}
+
+	s.Option["DefaultDependencies"] = false
+	b.Reset()
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	unit, _, _ := strings.Cut(b.String(), "[Service]")
+	if !strings.Contains(unit, "\nDefaultDependencies=no\n") {
+		t.Errorf("[Unit] does not contain DefaultDependencies=no, got:\n%s", unit)
+	}
+}
+
+func TestSystemdNotifyReload(t *testing.T) {
+	s := &systemd{Config: &Config{
+		Name:       "test",
+		Executable: "/usr/bin/app",
+		Option:     KeyValue{"NotifyReload": true, "RestartOnReload": true, "ReloadSignal": "usr1"},
+	}}
+	var b bytes.Buffer
+	if err := s.writeUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Type=notify-reload\nReloadSignal=SIGUSR1\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("unit does not contain\n%s\ngot:\n%s", want, b.String())
+	}
+	if strings.Contains(b.String(), "ExecReload") {
+		t.Errorf("notify-reload unit contains ExecReload, got:\n%s", b.String())
+	}
+
+	delete(s.Option, "RestartOnReload")
+	if err := s.writeUnit(&b); err == nil {
+		t.Error("writeUnit() with NotifyReload without RestartOnReload returned no error")
+	}
+}
+
+func TestUnitStateError(t *testing.T) {
+	tests := []struct {
+		action string
+		state  UnitState
+		ok     bool
+	}{
+		{"start", UnitState{"active", "running"}, true},
+		{"start", UnitState{"activating", "start"}, true},
+		{"start", UnitState{"activating", "auto-restart"}, false},
+		{"start", UnitState{"failed", "failed"}, false},
+		{"restart", UnitState{"inactive", "dead"}, false},
+		{"stop", UnitState{"inactive", "dead"}, true},
+		{"stop", UnitState{"failed", "failed"}, true},
+		{"stop", UnitState{"active", "running"}, false},
+	}
+	for _, tt := range tests {
+		err := unitStateError("app.service", tt.action, tt.state)
+		if (err == nil) != tt.ok {
+			t.Errorf("unitStateError(%s, %v) = %v, want ok %v", tt.action, tt.state, err, tt.ok)
+		}
+		if err != nil && tt.action != "stop" && !errors.Is(err, ErrNotRunning) {
+			t.Errorf("unitStateError(%s, %v) = %v, want %v", tt.action, tt.state, err, ErrNotRunning)
+		}
+	}
+}
+
+func TestSystemdDaemonReload(t *testing.T) {
+	var s Service = &systemd{Config: &Config{Name: "test", Option: KeyValue{"DaemonReload": false}}}
+	if _, ok := s.(DaemonReloader); !ok {
+		t.Fatal("systemd service is not a DaemonReloader")
+	}
+	if err := s.(*systemd).daemonReload(); err != nil {
+		t.Errorf("daemonReload() with DaemonReload false error = %v, want nil", err)
+	}
+}
+
+// fakeSystemctl puts a systemctl running script, a sh case on the arguments,
+// first in PATH.
+func fakeSystemctl(t *testing.T, script string) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte("#!/bin/sh\ncase \"$*\" in\n"+script+"\nesac\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestSystemdInstalledStopping(t *testing.T) {
+	fakeSystemctl(t, `"is-active test.service") echo deactivating; exit 3 ;;
+"list-unit-files -t service test.service") echo "test.service enabled enabled" ;;`)
+	s := &systemd{Config: &Config{Name: "test", Option: KeyValue{}}}
+	if ok, err := s.Installed(); err != nil || !ok {
+		t.Errorf("Installed() of a stopping unit = %v, %v, want true", ok, err)
+	}
+
+	fakeSystemctl(t, `"list-unit-files -t service test.service") echo "0 unit files listed." ;;`)
+	if ok, err := s.Installed(); err != nil || ok {
+		t.Errorf("Installed() without a unit file = %v, %v, want false", ok, err)
+	}
+}
+
+func TestSystemdKillStopping(t *testing.T) {
+	killed := filepath.Join(t.TempDir(), "killed")
+	fakeSystemctl(t, `"show -p LoadState,ActiveState,SubState test.service") printf "LoadState=loaded\nActiveState=deactivating\nSubState=stop-sigterm\n" ;;
+"kill -s SIGKILL test.service") touch `+killed+` ;;`)
+	s := &systemd{Config: &Config{Name: "test", Option: KeyValue{}}}
+	if err := s.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(killed); err != nil {
+		t.Errorf("Kill() of a deactivating unit did not run systemctl kill: %v", err)
+	}
+
+	fakeSystemctl(t, `"show -p LoadState,ActiveState,SubState test.service") printf "LoadState=loaded\nActiveState=inactive\nSubState=dead\n" ;;`)
+	if err := s.Kill(); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Kill() of an inactive unit error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestSystemdWaitStopped(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(state, []byte("deactivating"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The unit stops on the third is-active.
+	fakeSystemctl(t, `"is-active test.service") n=$(cat `+state+`.n 2>/dev/null); echo "x$n" > `+state+`.n
+  [ "$n" = xx ] && echo inactive > `+state+`; cat `+state+`; [ "$(cat `+state+`)" = inactive ] && exit 3; exit 0 ;;
+"list-unit-files -t service test.service") echo "test.service enabled enabled" ;;
+"show -p LoadState,ActiveState,SubState test.service") printf "LoadState=loaded\nActiveState=%s\n" "$(cat `+state+`)" ;;`)
+	s := &systemd{Config: &Config{Name: "test", Option: KeyValue{}}}
+	if err := WaitStopped(s, 5*time.Second); err != nil {
+		t.Fatalf("WaitStopped() of a deactivating unit error = %v, want nil", err)
+	}
+	if b, _ := os.ReadFile(state); string(b) != "inactive\n" {
+		t.Errorf("WaitStopped() returned with the unit %q, want inactive", b)
+	}
+}
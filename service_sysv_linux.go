@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"os/signal"
 	"strings"
 	"syscall"
@@ -35,7 +36,11 @@ func (s *sysv) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *sysv) Name() string {
+	return s.Config.Name
 }
 
 func (s *sysv) Platform() string {
@@ -53,6 +58,10 @@ func (s *sysv) configPath() (cp string, err error) {
 	return
 }
 
+func (s *sysv) location() (string, error) {
+	return s.configPath()
+}
+
 func (s *sysv) template() *template.Template {
 	customScript := s.Option.string(optionSysvScript, "")
 
@@ -63,6 +72,25 @@ func (s *sysv) template() *template.Template {
 }
 
 func (s *sysv) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+// validateAccount checks the user the service should run as exists, and
+// start-stop-daemon to switch to it.
+func (s *sysv) validateAccount() error {
+	if len(s.UserName) == 0 {
+		return nil
+	}
+	if err := s.Config.validateUser(); err != nil {
+		return err
+	}
+	if _, err := exec.LookPath("start-stop-daemon"); err != nil {
+		return fmt.Errorf("user %q for service %s needs start-stop-daemon: %v", s.UserName, s.Config.Name, err)
+	}
+	return nil
+}
+
+func (s *sysv) install() error {
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -71,10 +99,13 @@ func (s *sysv) Install() error {
 	if err == nil {
 		return fmt.Errorf("Init already exists: %s", confPath)
 	}
+	if err = s.validateAccount(); err != nil {
+		return err
+	}
 
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
@@ -101,21 +132,53 @@ func (s *sysv) Install() error {
 	if err = os.Chmod(confPath, 0755); err != nil {
 		return err
 	}
+	if !s.startAtBoot() {
+		return nil
+	}
+	return s.Enable()
+}
+
+// runlevelLinks returns the start and kill links of the init script.
+func (s *sysv) runlevelLinks() []string {
+	var links []string
 	for _, i := range [...]string{"2", "3", "4", "5"} {
-		if err = os.Symlink(confPath, "/etc/rc"+i+".d/S50"+s.Name); err != nil {
-			continue
-		}
+		links = append(links, "/etc/rc"+i+".d/S50"+s.Config.Name)
 	}
 	for _, i := range [...]string{"0", "1", "6"} {
-		if err = os.Symlink(confPath, "/etc/rc"+i+".d/K02"+s.Name); err != nil {
+		links = append(links, "/etc/rc"+i+".d/K02"+s.Config.Name)
+	}
+	return links
+}
+
+// Enable links the init script into the runlevel directories. Runlevel
+// directories that don't exist on the system are skipped.
+func (s *sysv) Enable() error {
+	confPath, err := s.configPath()
+	if err != nil {
+		return err
+	}
+	for _, link := range s.runlevelLinks() {
+		if err = os.Symlink(confPath, link); err != nil {
 			continue
 		}
 	}
+	return nil
+}
 
+func (s *sysv) Disable() error {
+	for _, link := range s.runlevelLinks() {
+		if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return nil
 }
 
 func (s *sysv) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *sysv) uninstall() error {
 	cp, err := s.configPath()
 	if err != nil {
 		return err
@@ -123,36 +186,56 @@ func (s *sysv) Uninstall() error {
 	if err := os.Remove(cp); err != nil {
 		return err
 	}
-	return nil
+	return s.Disable()
 }
 
 func (s *sysv) Logger(errs chan<- error) (Logger, error) {
-	if system.Interactive() {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 func (s *sysv) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 func (s *sysv) Run() (err error) {
+	if err = runInit(s.Option); err != nil {
+		return err
+	}
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	if err = setNice(s.Option); err != nil {
+		return err
+	}
+
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *sysv) Status() (Status, error) {
-	_, out, err := runWithOutput("service", s.Name, "status")
+	_, out, err := runWithOutput("service", s.Config.Name, "status")
 	if err != nil {
 		return StatusUnknown, err
 	}
@@ -167,12 +250,32 @@ func (s *sysv) Status() (Status, error) {
 	}
 }
 
+func (s *sysv) Installed() (bool, error) {
+	return installed(s)
+}
+
+func (s *sysv) Usage() (ResourceUsage, error) {
+	pid, err := pidFromFile("/var/run/" + s.Config.Name + ".pid")
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	return processUsage(pid)
+}
+
+func (s *sysv) Kill() error {
+	pid, err := pidFromFile("/var/run/" + s.Config.Name + ".pid")
+	if err != nil {
+		return err
+	}
+	return killProcess(pid)
+}
+
 func (s *sysv) Start() error {
-	return run("service", s.Name, "start")
+	return run("service", s.Config.Name, "start")
 }
 
 func (s *sysv) Stop() error {
-	return run("service", s.Name, "stop")
+	return run("service", s.Config.Name, "stop")
 }
 
 func (s *sysv) Restart() error {
@@ -206,9 +309,12 @@ name=$(basename $(readlink -f $0))
 pid_file="/var/run/$name.pid"
 stdout_log="{{.LogDirectory}}/$name.log"
 stderr_log="{{.LogDirectory}}/$name.err"
+{{- if .UserName}}
+user={{.UserName|shellQuote}}
+{{- end}}
 
 {{range $k, $v := .EnvVars -}}
-export {{$k}}={{$v}}
+export {{$k}}={{$v|shellQuote}}
 {{end -}}
 
 [ -e /etc/sysconfig/$name ] && . /etc/sysconfig/$name
@@ -228,8 +334,14 @@ case "$1" in
         else
             echo "Starting $name"
             {{if .WorkingDirectory}}cd '{{.WorkingDirectory}}'{{end}}
+{{- if .UserName}}
+            touch "$stdout_log" "$stderr_log" && chown "$user" "$stdout_log" "$stderr_log"
+            start-stop-daemon --start --background --make-pidfile --pidfile "$pid_file" --chuid "$user" \
+                --startas /bin/sh -- -c "exec $cmd >> \"$stdout_log\" 2>> \"$stderr_log\""
+{{- else}}
             $cmd >> "$stdout_log" 2>> "$stderr_log" &
             echo $! > "$pid_file"
+{{- end}}
             if ! is_running; then
                 echo "Unable to start, see $stdout_log and $stderr_log"
                 exit 1
@@ -0,0 +1,38 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSysvUser(t *testing.T) {
+	s := &sysv{Config: &Config{Name: "test", UserName: "app user", Option: KeyValue{}}}
+	to := &struct {
+		*Config
+		Path         string
+		LogDirectory string
+	}{s.Config, "/usr/bin/app", defaultLogDirectory}
+	var b bytes.Buffer
+	if err := s.template().Execute(&b, to); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"\nuser='app user'\n", `--chuid "$user"`} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("script does not contain %q:\n%s", want, b.String())
+		}
+	}
+
+	s.UserName = ""
+	b.Reset()
+	if err := s.template().Execute(&b, to); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "--chuid") {
+		t.Errorf("script without UserName switches user:\n%s", b.String())
+	}
+}
@@ -13,49 +13,198 @@ import (
 	"io"
 	"io/ioutil"
 	"log/syslog"
+	"os"
 	"os/exec"
+	"os/signal"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 )
 
 const defaultLogDirectory = "/var/log"
 
-func newSysLogger(name string, errs chan<- error) (Logger, error) {
+// reloadSignals are the signals ReloadSignal may name.
+var reloadSignals = map[string]syscall.Signal{
+	"HUP":   syscall.SIGHUP,
+	"USR1":  syscall.SIGUSR1,
+	"USR2":  syscall.SIGUSR2,
+	"WINCH": syscall.SIGWINCH,
+}
+
+// watchReload restarts the program, calling Stop then Start, on the reload
+// signal when the RestartOnReload option is set, telling systemd when the
+// reload starts and ends. The returned function stops watching and waits for
+// a restart in progress to finish.
+func watchReload(i Interface, s Service, kv KeyValue) func() {
+	if !kv.bool(optionRestartOnReload, false) {
+		return func() {}
+	}
+	name := reloadSignal(kv)
+	sig, ok := reloadSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		ConsoleLogger.Errorf("Unsupported reload signal %q, not restarting on reload", name)
+		return func() {}
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, sig)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			case <-sigChan:
+			}
+			ConsoleLogger.Info("Reload signal received, restarting")
+			if err := sdNotifyReloading(); err != nil {
+				ConsoleLogger.Errorf("Failed to notify systemd of the reload: %v", err)
+			}
+			if err := restartInProcess(i, s); err != nil {
+				ConsoleLogger.Errorf("Restart failed to start: %v", err)
+				if err := sdNotifyReloadFailed(err); err != nil {
+					ConsoleLogger.Errorf("Failed to notify systemd of the reload: %v", err)
+				}
+				continue
+			}
+			if err := sdNotify("READY=1"); err != nil {
+				ConsoleLogger.Errorf("Failed to notify systemd of the reload: %v", err)
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigChan)
+		close(done)
+		wg.Wait()
+	}
+}
+
+// directoryRoots are the parents of the RuntimeDirectory, StateDirectory
+// and LogsDirectory options when Install creates them instead of systemd.
+var directoryRoots = map[string]string{
+	optionRuntimeDirectory: "/var/run",
+	optionStateDirectory:   "/var/lib",
+	optionLogsDirectory:    "/var/log",
+}
+
+// privilegeHint is added to permission errors from Install.
+const privilegeHint = "run as root"
+
+// procStatusFile holds the capability sets of the process on Linux.
+var procStatusFile = "/proc/self/status"
+
+// capSysAdmin is the CAP_SYS_ADMIN bit in a Linux capability set.
+const capSysAdmin = 21
+
+func canManage() (bool, error) {
+	if os.Geteuid() == 0 {
+		return true, nil
+	}
+	if runtime.GOOS != "linux" {
+		return false, nil
+	}
+	return hasCapability(procStatusFile, capSysAdmin)
+}
+
+func relaunchElevated() (bool, error) {
+	return false, ErrNotSupported
+}
+
+// hasCapability reports whether the effective capability set in a
+// /proc/<pid>/status file contains the capability bit.
+func hasCapability(path string, bit uint) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		set, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return false, err
+		}
+		return set&(1<<bit) != 0, nil
+	}
+	return false, nil
+}
+
+// newSysLogger returns the syslog logger of the service, or the file logger
+// if the LogFile option is set.
+func newSysLogger(c *Config, errs chan<- error) (Logger, error) {
+	if c.Option.string(optionLogger, "") == optionLoggerNull {
+		return NullLogger, nil
+	}
+	if c.Option.string(optionLogFile, "") != "" {
+		return c.fileLogger(errs)
+	}
+	name := c.Name
 	w, err := syslog.New(syslog.LOG_INFO, name)
 	if err != nil {
 		return nil, err
 	}
-	return sysLogger{w, errs}, nil
+	return sysLogger{w, errs, name, nil}, nil
 }
 
 type sysLogger struct {
 	*syslog.Writer
-	errs chan<- error
+	errs   chan<- error
+	name   string
+	fields map[string]interface{}
 }
 
 func (s sysLogger) send(err error) error {
-	if err != nil && s.errs != nil {
-		s.errs <- err
-	}
+	sendError(s.errs, err)
 	return err
 }
 
+// write logs msg, sending the fields to the journal if it is running.
+func (s sysLogger) write(p syslog.Priority, msg string) error {
+	if len(s.fields) > 0 {
+		if sent, err := journalSend(s.name, p, msg, s.fields); sent {
+			return s.send(err)
+		}
+		msg += formatFields(s.fields)
+	}
+	switch p {
+	case syslog.LOG_ERR:
+		return s.send(s.Writer.Err(msg))
+	case syslog.LOG_WARNING:
+		return s.send(s.Writer.Warning(msg))
+	default:
+		return s.send(s.Writer.Info(msg))
+	}
+}
+
 func (s sysLogger) Error(v ...interface{}) error {
-	return s.send(s.Writer.Err(fmt.Sprint(v...)))
+	return s.write(syslog.LOG_ERR, fmt.Sprint(v...))
 }
 func (s sysLogger) Warning(v ...interface{}) error {
-	return s.send(s.Writer.Warning(fmt.Sprint(v...)))
+	return s.write(syslog.LOG_WARNING, fmt.Sprint(v...))
 }
 func (s sysLogger) Info(v ...interface{}) error {
-	return s.send(s.Writer.Info(fmt.Sprint(v...)))
+	return s.write(syslog.LOG_INFO, fmt.Sprint(v...))
 }
 func (s sysLogger) Errorf(format string, a ...interface{}) error {
-	return s.send(s.Writer.Err(fmt.Sprintf(format, a...)))
+	return s.write(syslog.LOG_ERR, fmt.Sprintf(format, a...))
 }
 func (s sysLogger) Warningf(format string, a ...interface{}) error {
-	return s.send(s.Writer.Warning(fmt.Sprintf(format, a...)))
+	return s.write(syslog.LOG_WARNING, fmt.Sprintf(format, a...))
 }
 func (s sysLogger) Infof(format string, a ...interface{}) error {
-	return s.send(s.Writer.Info(fmt.Sprintf(format, a...)))
+	return s.write(syslog.LOG_INFO, fmt.Sprintf(format, a...))
+}
+func (s sysLogger) With(fields map[string]interface{}) Logger {
+	s.fields = withFields(s.fields, fields)
+	return s
 }
 
 func run(command string, arguments ...string) error {
@@ -140,3 +289,36 @@ func isExitError(err error) (int, bool) {
 
 	return 0, false
 }
+
+// chown sets the owner of path to the user and group names. An empty group
+// name is the primary group of the user.
+func chown(path, userName, groupName string) error {
+	if userName == "" && groupName == "" {
+		return nil
+	}
+	uid, gid := -1, -1
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			return err
+		}
+		if uid, err = strconv.Atoi(u.Uid); err != nil {
+			return err
+		}
+		if groupName == "" {
+			if gid, err = strconv.Atoi(u.Gid); err != nil {
+				return err
+			}
+		}
+	}
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			return err
+		}
+		if gid, err = strconv.Atoi(g.Gid); err != nil {
+			return err
+		}
+	}
+	return os.Chown(path, uid, gid)
+}
@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/signal"
 	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
 	"text/template"
@@ -49,7 +50,11 @@ func (s *upstart) String() string {
 	if len(s.DisplayName) > 0 {
 		return s.DisplayName
 	}
-	return s.Name
+	return s.Config.Name
+}
+
+func (s *upstart) Name() string {
+	return s.Config.Name
 }
 
 func (s *upstart) Platform() string {
@@ -61,6 +66,11 @@ func (s *upstart) Platform() string {
 // Upstart will be replaced by systemd in most cases anyway.
 var errNoUserServiceUpstart = errors.New("User services are not supported on Upstart.")
 
+// upstartList returns the names of the jobs in /etc/init.
+func upstartList() ([]string, error) {
+	return listDir("/etc/init", ".conf")
+}
+
 func (s *upstart) configPath() (cp string, err error) {
 	if s.Option.bool(optionUserService, optionUserServiceDefault) {
 		err = errNoUserServiceUpstart
@@ -70,6 +80,10 @@ func (s *upstart) configPath() (cp string, err error) {
 	return
 }
 
+func (s *upstart) location() (string, error) {
+	return s.configPath()
+}
+
 func (s *upstart) hasKillStanza() bool {
 	defaultValue := true
 	version := s.getUpstartVersion()
@@ -126,6 +140,10 @@ func (s *upstart) template() *template.Template {
 }
 
 func (s *upstart) Install() error {
+	return runInstall(s.i, s, s.Config, s.install)
+}
+
+func (s *upstart) install() error {
 	confPath, err := s.configPath()
 	if err != nil {
 		return err
@@ -137,7 +155,7 @@ func (s *upstart) Install() error {
 
 	f, err := os.Create(confPath)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer f.Close()
 
@@ -162,10 +180,22 @@ func (s *upstart) Install() error {
 		s.Option.string(optionLogDirectory, defaultLogDirectory),
 	}
 
-	return s.template().Execute(f, to)
+	err = s.template().Execute(f, to)
+	if err != nil {
+		return err
+	}
+
+	if s.startAtBoot() {
+		return nil
+	}
+	return s.Disable()
 }
 
 func (s *upstart) Uninstall() error {
+	return runUninstall(s.i, s, s.Config, s.uninstall)
+}
+
+func (s *upstart) uninstall() error {
 	cp, err := s.configPath()
 	if err != nil {
 		return err
@@ -173,60 +203,156 @@ func (s *upstart) Uninstall() error {
 	if err := os.Remove(cp); err != nil {
 		return err
 	}
+	// Enable removes the override file left by Disable.
+	return s.Enable()
+}
+
+// overridePath returns the path of the override file, a job with the
+// "manual" stanza in its override file is not started on boot.
+func (s *upstart) overridePath() (string, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cp, ".conf") + ".override", nil
+}
+
+func (s *upstart) installedStartType() (string, error) {
+	op, err := s.overridePath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(op); err == nil {
+		return ServiceStartManual, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	return ServiceStartAutomatic, nil
+}
+
+func (s *upstart) Enable() error {
+	op, err := s.overridePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(op); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	return nil
 }
 
+func (s *upstart) Disable() error {
+	op, err := s.overridePath()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(op, []byte("manual\n"), 0644)
+}
+
 func (s *upstart) Logger(errs chan<- error) (Logger, error) {
-	if system.Interactive() {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return s.Config.interactiveLogger(), nil
 	}
 	return s.SystemLogger(errs)
 }
 func (s *upstart) SystemLogger(errs chan<- error) (Logger, error) {
-	return newSysLogger(s.Name, errs)
+	return newSysLogger(s.Config, errs)
 }
 
 func (s *upstart) Run() (err error) {
+	if err = runInit(s.Option); err != nil {
+		return err
+	}
+	restore, err := captureOutput(s, s.Option)
+	if err != nil {
+		return err
+	}
+	defer restore()
+	if err = setNice(s.Option); err != nil {
+		return err
+	}
+
+	notifyState(s.i, s, StateStartPending)
 	err = s.i.Start(s)
 	if err != nil {
+		notifyState(s.i, s, StateStopped)
 		return err
 	}
+	notifyState(s.i, s, StateRunning)
+	stopWatch := watch(s.i, s, s.Option)
 
 	s.Option.funcSingle(optionRunWait, func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
 		<-sigChan
 	})()
+	stopWatch()
 
-	return s.i.Stop(s)
+	notifyState(s.i, s, StateStopPending)
+	err = stopInteractive(s.Option, func() error { return s.i.Stop(s) })
+	notifyState(s.i, s, StateStopped)
+	return err
 }
 
 func (s *upstart) Status() (Status, error) {
-	exitCode, out, err := runWithOutput("initctl", "status", s.Name)
+	exitCode, out, err := runWithOutput("initctl", "status", s.Config.Name)
 	if exitCode == 0 && err != nil {
 		return StatusUnknown, err
 	}
 
 	switch {
-	case strings.HasPrefix(out, fmt.Sprintf("%s start/running", s.Name)):
+	case strings.HasPrefix(out, fmt.Sprintf("%s start/running", s.Config.Name)):
 		return StatusRunning, nil
-	case strings.HasPrefix(out, fmt.Sprintf("%s stop/waiting", s.Name)):
+	case strings.HasPrefix(out, fmt.Sprintf("%s stop/waiting", s.Config.Name)):
 		return StatusStopped, nil
 	default:
 		return StatusUnknown, ErrNotInstalled
 	}
 }
 
+func (s *upstart) Installed() (bool, error) {
+	return installed(s)
+}
+
+// pid reads the PID of the running job from initctl status.
+func (s *upstart) pid() (int, error) {
+	_, out, err := runWithOutput("initctl", "status", s.Config.Name)
+	if err != nil {
+		return 0, err
+	}
+	matches := regexp.MustCompile(`process (\d+)`).FindStringSubmatch(out)
+	if len(matches) != 2 {
+		return 0, ErrNotRunning
+	}
+	return strconv.Atoi(matches[1])
+}
+
+func (s *upstart) Usage() (ResourceUsage, error) {
+	pid, err := s.pid()
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	return processUsage(pid)
+}
+
+func (s *upstart) Kill() error {
+	pid, err := s.pid()
+	if err != nil {
+		return err
+	}
+	return killProcess(pid)
+}
+
 func (s *upstart) Start() error {
-	return run("initctl", "start", s.Name)
+	return run("initctl", "start", s.Config.Name)
 }
 
 func (s *upstart) Stop() error {
-	return run("initctl", "stop", s.Name)
+	return run("initctl", "stop", s.Config.Name)
 }
 
 func (s *upstart) Restart() error {
-	return run("initctl", "restart", s.Name)
+	return run("initctl", "restart", s.Config.Name)
 }
 
 // The upstart script should stop with an INT or the Go runtime will terminate
@@ -9,11 +9,15 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+	"unsafe"
 
 	"golang.org/x/sys/windows"
 	"golang.org/x/sys/windows/registry"
@@ -25,19 +29,31 @@ import (
 const (
 	version = "windows-service"
 
-	StartType             = "StartType"
-	ServiceStartManual    = "manual"
-	ServiceStartDisabled  = "disabled"
-	ServiceStartAutomatic = "automatic"
-
 	OnFailure              = "OnFailure"
 	OnFailureRestart       = "restart"
 	OnFailureReboot        = "reboot"
 	OnFailureNoAction      = "noaction"
 	OnFailureDelayDuration = "OnFailureDelayDuration"
 	OnFailureResetPeriod   = "OnFailureResetPeriod"
+	OnFailureForNonCrash   = "OnFailureForNonCrash"
+	LoadOrderGroup         = "LoadOrderGroup"
+	RequestTag             = "RequestTag"
+	PreferredNode          = "PreferredNode"
+	AllowStartStop         = "AllowStartStop"
+	StopDependents         = "StopDependents"
+	SuffixDisplayName      = "SuffixDisplayName"
+	VirtualAccount         = "VirtualAccount"
+	PreshutdownTimeout     = "PreshutdownTimeout"
+	InstallScript          = "InstallScript"
+	InstallSettleTimeout   = "InstallSettleTimeout"
+	LoadUserEnvironment    = "LoadUserEnvironment"
 
 	errnoServiceDoesNotExist syscall.Errno = 1060
+
+	// privilegeHint is added to access denied errors from Install.
+	privilegeHint = "run as Administrator"
+
+	defaultSettleTimeout = 2 * time.Second
 )
 
 type windowsService struct {
@@ -50,8 +66,9 @@ type windowsService struct {
 
 // WindowsLogger allows using windows specific logging methods.
 type WindowsLogger struct {
-	ev   *eventlog.Log
-	errs chan<- error
+	ev     *eventlog.Log
+	errs   chan<- error
+	fields map[string]interface{}
 }
 
 type windowsSystem struct{}
@@ -73,78 +90,150 @@ func (windowsSystem) New(i Interface, c *Config) (Service, error) {
 	return ws, nil
 }
 
+// statuses queries the services over a single service manager connection.
+func (windowsSystem) statuses(names []string) (map[string]Status, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Disconnect()
+
+	statuses := make(map[string]Status, len(names))
+	for _, name := range names {
+		status, err := queryStatus(m, name)
+		if err != nil && err != ErrNotInstalled {
+			return nil, err
+		}
+		statuses[name] = status
+	}
+	return statuses, nil
+}
+
+func (windowsSystem) connect() (managerConn, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return nil, err
+	}
+	return windowsConn{m}, nil
+}
+
+// windowsConn keeps the service control manager open for a Manager.
+type windowsConn struct {
+	m *mgr.Mgr
+}
+
+func (c windowsConn) status(name string) (Status, error) {
+	return queryStatus(c.m, name)
+}
+
+func (c windowsConn) start(name string) error {
+	return startService(c.m, name)
+}
+
+func (c windowsConn) stop(name string) error {
+	return stopService(c.m, name, getStopTimeout())
+}
+
+func (c windowsConn) restart(name string) error {
+	return restartService(c.m, name, getStopTimeout())
+}
+
+func (c windowsConn) list() ([]string, error) {
+	return c.m.ListServices()
+}
+
+func (c windowsConn) close() error {
+	return c.m.Disconnect()
+}
+
 func init() {
 	ChooseSystem(windowsSystem{})
 }
 
 func (l WindowsLogger) send(err error) error {
-	if err == nil {
-		return nil
-	}
-	if l.errs != nil {
-		l.errs <- err
-	}
+	sendError(l.errs, err)
 	return err
 }
 
+// report logs msg with the fields added, and the fields as further
+// insertion strings for tools that read the event data.
+func (l WindowsLogger) report(etype uint16, eventID uint32, msg string) error {
+	strs := append([]string{msg + formatFields(l.fields)}, fieldPairs(l.fields)...)
+	ptrs := make([]*uint16, len(strs))
+	for i, str := range strs {
+		p, err := syscall.UTF16PtrFromString(str)
+		if err != nil {
+			return err
+		}
+		ptrs[i] = p
+	}
+	return windows.ReportEvent(l.ev.Handle, etype, 0, eventID, 0, uint16(len(ptrs)), 0, &ptrs[0], nil)
+}
+
+// With returns a logger that adds the fields to each message.
+func (l WindowsLogger) With(fields map[string]interface{}) Logger {
+	l.fields = withFields(l.fields, fields)
+	return l
+}
+
 // Error logs an error message.
 func (l WindowsLogger) Error(v ...interface{}) error {
-	return l.send(l.ev.Error(3, fmt.Sprint(v...)))
+	return l.send(l.report(windows.EVENTLOG_ERROR_TYPE, 3, fmt.Sprint(v...)))
 }
 
 // Warning logs an warning message.
 func (l WindowsLogger) Warning(v ...interface{}) error {
-	return l.send(l.ev.Warning(2, fmt.Sprint(v...)))
+	return l.send(l.report(windows.EVENTLOG_WARNING_TYPE, 2, fmt.Sprint(v...)))
 }
 
 // Info logs an info message.
 func (l WindowsLogger) Info(v ...interface{}) error {
-	return l.send(l.ev.Info(1, fmt.Sprint(v...)))
+	return l.send(l.report(windows.EVENTLOG_INFORMATION_TYPE, 1, fmt.Sprint(v...)))
 }
 
 // Errorf logs an error message.
 func (l WindowsLogger) Errorf(format string, a ...interface{}) error {
-	return l.send(l.ev.Error(3, fmt.Sprintf(format, a...)))
+	return l.send(l.report(windows.EVENTLOG_ERROR_TYPE, 3, fmt.Sprintf(format, a...)))
 }
 
 // Warningf logs an warning message.
 func (l WindowsLogger) Warningf(format string, a ...interface{}) error {
-	return l.send(l.ev.Warning(2, fmt.Sprintf(format, a...)))
+	return l.send(l.report(windows.EVENTLOG_WARNING_TYPE, 2, fmt.Sprintf(format, a...)))
 }
 
 // Infof logs an info message.
 func (l WindowsLogger) Infof(format string, a ...interface{}) error {
-	return l.send(l.ev.Info(1, fmt.Sprintf(format, a...)))
+	return l.send(l.report(windows.EVENTLOG_INFORMATION_TYPE, 1, fmt.Sprintf(format, a...)))
 }
 
 // NError logs an error message and an event ID.
 func (l WindowsLogger) NError(eventID uint32, v ...interface{}) error {
-	return l.send(l.ev.Error(eventID, fmt.Sprint(v...)))
+	return l.send(l.report(windows.EVENTLOG_ERROR_TYPE, eventID, fmt.Sprint(v...)))
 }
 
 // NWarning logs an warning message and an event ID.
 func (l WindowsLogger) NWarning(eventID uint32, v ...interface{}) error {
-	return l.send(l.ev.Warning(eventID, fmt.Sprint(v...)))
+	return l.send(l.report(windows.EVENTLOG_WARNING_TYPE, eventID, fmt.Sprint(v...)))
 }
 
 // NInfo logs an info message and an event ID.
 func (l WindowsLogger) NInfo(eventID uint32, v ...interface{}) error {
-	return l.send(l.ev.Info(eventID, fmt.Sprint(v...)))
+	return l.send(l.report(windows.EVENTLOG_INFORMATION_TYPE, eventID, fmt.Sprint(v...)))
 }
 
 // NErrorf logs an error message and an event ID.
 func (l WindowsLogger) NErrorf(eventID uint32, format string, a ...interface{}) error {
-	return l.send(l.ev.Error(eventID, fmt.Sprintf(format, a...)))
+	return l.send(l.report(windows.EVENTLOG_ERROR_TYPE, eventID, fmt.Sprintf(format, a...)))
 }
 
 // NWarningf logs an warning message and an event ID.
 func (l WindowsLogger) NWarningf(eventID uint32, format string, a ...interface{}) error {
-	return l.send(l.ev.Warning(eventID, fmt.Sprintf(format, a...)))
+	return l.send(l.report(windows.EVENTLOG_WARNING_TYPE, eventID, fmt.Sprintf(format, a...)))
 }
 
 // NInfof logs an info message and an event ID.
 func (l WindowsLogger) NInfof(eventID uint32, format string, a ...interface{}) error {
-	return l.send(l.ev.Info(eventID, fmt.Sprintf(format, a...)))
+	return l.send(l.report(windows.EVENTLOG_INFORMATION_TYPE, eventID, fmt.Sprintf(format, a...)))
 }
 
 var interactive = false
@@ -161,7 +250,11 @@ func (ws *windowsService) String() string {
 	if len(ws.DisplayName) > 0 {
 		return ws.DisplayName
 	}
-	return ws.Name
+	return ws.Config.Name
+}
+
+func (ws *windowsService) Name() string {
+	return ws.Config.Name
 }
 
 func (ws *windowsService) Platform() string {
@@ -180,15 +273,26 @@ func (ws *windowsService) getError() error {
 }
 
 func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
-	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
+	cmdsAccepted := svc.AcceptStop | svc.AcceptShutdown
+	if ws.preshutdownTimeout() > 0 {
+		// Stop on the preshutdown notification, which comes with the
+		// service's own timeout instead of the machine wide one.
+		cmdsAccepted = svc.AcceptStop | svc.AcceptPreShutdown
+	}
+	if ws.Option.bool(optionRestartOnReload, false) {
+		cmdsAccepted |= svc.AcceptParamChange
+	}
 	changes <- svc.Status{State: svc.StartPending}
+	notifyState(ws.i, ws, StateStartPending)
 
 	if err := ws.i.Start(ws); err != nil {
 		ws.setError(err)
+		notifyState(ws.i, ws, StateStopped)
 		return true, 1
 	}
 
 	changes <- svc.Status{State: svc.Running, Accepts: cmdsAccepted}
+	notifyState(ws.i, ws, StateRunning)
 loop:
 	for {
 		c := <-r
@@ -196,58 +300,242 @@ loop:
 		case svc.Interrogate:
 			changes <- c.CurrentStatus
 		case svc.Stop:
-			changes <- svc.Status{State: svc.StopPending}
-			if err := ws.i.Stop(ws); err != nil {
+			if err := ws.stopPending(changes, func() error { return ws.i.Stop(ws) }); err != nil {
 				ws.setError(err)
+				notifyState(ws.i, ws, StateStopped)
 				return true, 2
 			}
 			break loop
-		case svc.Shutdown:
-			changes <- svc.Status{State: svc.StopPending}
-			var err error
-			if wsShutdown, ok := ws.i.(Shutdowner); ok {
-				err = wsShutdown.Shutdown(ws)
-			} else {
-				err = ws.i.Stop(ws)
-			}
+		case svc.Shutdown, svc.PreShutdown:
+			err := ws.stopPending(changes, func() error {
+				if wsShutdown, ok := ws.i.(Shutdowner); ok {
+					return wsShutdown.Shutdown(ws)
+				}
+				return ws.i.Stop(ws)
+			})
 			if err != nil {
 				ws.setError(err)
+				notifyState(ws.i, ws, StateStopped)
 				return true, 2
 			}
 			break loop
+		case svc.ParamChange:
+			if err := restartInProcess(ws.i, ws); err != nil {
+				ws.setError(err)
+				notifyState(ws.i, ws, StateStopped)
+				return true, 1
+			}
+			changes <- c.CurrentStatus
 		default:
 			continue loop
 		}
 	}
 
+	notifyState(ws.i, ws, StateStopped)
 	return false, 0
 }
 
-func lowPrivMgr() (*mgr.Mgr, error) {
-	h, err := windows.OpenSCManager(nil, nil, windows.SC_MANAGER_CONNECT|windows.SC_MANAGER_ENUMERATE_SERVICE)
+// settleTimeout returns the InstallSettleTimeout option.
+func (ws *windowsService) settleTimeout() time.Duration {
+	d, err := time.ParseDuration(ws.Option.string(InstallSettleTimeout, ""))
+	if err != nil || d < 0 {
+		return defaultSettleTimeout
+	}
+	return d
+}
+
+// settleErrors are the errors of the SCM that go away by waiting after
+// CreateService, while the service database settles.
+var settleErrors = []error{
+	windows.ERROR_SERVICE_MARKED_FOR_DELETE,
+	windows.ERROR_SERVICE_DATABASE_LOCKED,
+	windows.ERROR_SERVICE_DOES_NOT_EXIST,
+}
+
+// eventLogSettleErrors are the registry errors of creating the event log
+// source that go away by waiting, such as while the key of an earlier
+// install is still being deleted or is held open by another process.
+var eventLogSettleErrors = []error{
+	windows.ERROR_KEY_DELETED,
+	windows.ERROR_ACCESS_DENIED,
+	windows.ERROR_SHARING_VIOLATION,
+	windows.ERROR_LOCK_VIOLATION,
+}
+
+// settle runs fn until it succeeds or timeout passes, for the install steps
+// that follow CreateService. Only the settleErrors are retried, the others
+// are returned right away.
+func settle(timeout time.Duration, fn func() error) error {
+	return settleOn(timeout, settleErrors, fn)
+}
+
+// settleOn is settle retrying the errors in retry.
+func settleOn(timeout time.Duration, retry []error, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	for delay := 100 * time.Millisecond; ; delay *= 2 {
+		err := fn()
+		if err == nil || !isOneOf(err, retry) || time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		time.Sleep(delay)
+	}
+}
+
+// isOneOf reports if err is one of targets.
+func isOneOf(err error, targets []error) bool {
+	for _, target := range targets {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// preshutdownTimeout returns the PreshutdownTimeout option, zero when unset.
+func (ws *windowsService) preshutdownTimeout() time.Duration {
+	d, err := time.ParseDuration(ws.Option.string(PreshutdownTimeout, ""))
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// servicePreshutdownInfo is SERVICE_PRESHUTDOWN_INFO.
+type servicePreshutdownInfo struct {
+	preshutdownTimeout uint32
+}
+
+// setPreshutdownTimeout sets the time the SCM waits for the service after
+// the preshutdown notification. It is stored with the service, so deleting
+// the service removes it.
+func setPreshutdownTimeout(s *mgr.Service, timeout time.Duration) error {
+	info := servicePreshutdownInfo{preshutdownTimeout: uint32(timeout / time.Millisecond)}
+	return windows.ChangeServiceConfig2(s.Handle, windows.SERVICE_CONFIG_PRESHUTDOWN_INFO, (*byte)(unsafe.Pointer(&info)))
+}
+
+// stopTimeout returns the StopTimeout option, or the system wide time before
+// windows kills a service when not set.
+func (ws *windowsService) stopTimeout() time.Duration {
+	if d, err := time.ParseDuration(ws.Option.string(optionStopTimeout, "")); err == nil && d > 0 {
+		return d
+	}
+	return getStopTimeout()
+}
+
+// stopPending runs stop while reporting StopPending with a wait hint of the
+// stop timeout. The check point is incremented every second so the SCM
+// doesn't consider a slow stop hung, until the stop timeout has passed.
+func (ws *windowsService) stopPending(changes chan<- svc.Status, stop func() error) error {
+	timeout := ws.stopTimeout()
+	status := svc.Status{
+		State:    svc.StopPending,
+		WaitHint: uint32(timeout / time.Millisecond),
+	}
+	changes <- status
+	notifyState(ws.i, ws, StateStopPending)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stop()
+	}()
+
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case err := <-done:
+			return err
+		case <-tick.C:
+			status.CheckPoint++
+			changes <- status
+		case <-deadline:
+			tick.Stop()
+		}
+	}
+}
+
+// Access rights each operation asks for, so an administrator can grant a
+// non-administrator account just what it needs in the security descriptors
+// of the service control manager and of the service:
+//
+//	Install:                     SC_MANAGER_CONNECT, SC_MANAGER_CREATE_SERVICE on the manager;
+//	                             SERVICE_CHANGE_CONFIG on a service of the same name, to tell
+//	                             if it is marked for deletion
+//	Uninstall:                   SC_MANAGER_CONNECT on the manager; DELETE, SERVICE_STOP,
+//	                             SERVICE_QUERY_CONFIG, SERVICE_QUERY_STATUS,
+//	                             SERVICE_ENUMERATE_DEPENDENTS on the service, and the Stop
+//	                             rights on the dependents with StopDependents
+//	Dependents:                  SC_MANAGER_CONNECT on the manager; SERVICE_ENUMERATE_DEPENDENTS
+//	                             on the service
+//	Start, Stop, Restart:        SC_MANAGER_CONNECT on the manager; SERVICE_START, SERVICE_STOP,
+//	                             SERVICE_QUERY_CONFIG, SERVICE_QUERY_STATUS on the service
+//	Status, Usage, UnitState:    as Start, the service is opened with the same rights
+//	Enable, Disable:             SC_MANAGER_CONNECT on the manager; SERVICE_QUERY_CONFIG,
+//	                             SERVICE_CHANGE_CONFIG on the service
+//	SetDelayedAutoStart:         as Enable
+//	Kill:                        as Status, and PROCESS_TERMINATE on the service process
+//
+// Install and Uninstall also add and remove the event log source and Install
+// writes EnvVars, both under HKEY_LOCAL_MACHINE, which by default only an
+// administrator may do.
+const (
+	installMgrAccess   = windows.SC_MANAGER_CONNECT | windows.SC_MANAGER_CREATE_SERVICE
+	uninstallAccess    = windows.DELETE | windows.SERVICE_ENUMERATE_DEPENDENTS | startStopAccess
+	changeConfigAccess = windows.SERVICE_QUERY_CONFIG | windows.SERVICE_CHANGE_CONFIG
+)
+
+// openMgr connects to the service control manager with only the given
+// rights, where mgr.Connect asks for all of them.
+func openMgr(access uint32) (*mgr.Mgr, error) {
+	h, err := windows.OpenSCManager(nil, nil, access)
 	if err != nil {
 		return nil, err
 	}
 	return &mgr.Mgr{Handle: h}, nil
 }
 
-func lowPrivSvc(m *mgr.Mgr, name string) (*mgr.Service, error) {
-	h, err := windows.OpenService(
-		m.Handle, syscall.StringToUTF16Ptr(name),
-		windows.SERVICE_QUERY_CONFIG|windows.SERVICE_QUERY_STATUS|windows.SERVICE_START|windows.SERVICE_STOP)
+// openSvc opens the service with only the given rights, where
+// m.OpenService asks for all of them.
+func openSvc(m *mgr.Mgr, name string, access uint32) (*mgr.Service, error) {
+	p, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.OpenService(m.Handle, p, access)
 	if err != nil {
 		return nil, err
 	}
 	return &mgr.Service{Handle: h, Name: name}, nil
 }
 
+func canManage() (bool, error) {
+	m, err := openMgr(installMgrAccess)
+	if err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return false, nil
+		}
+		return false, err
+	}
+	m.Disconnect()
+	return true, nil
+}
+
+func lowPrivMgr() (*mgr.Mgr, error) {
+	return openMgr(windows.SC_MANAGER_CONNECT | windows.SC_MANAGER_ENUMERATE_SERVICE)
+}
+
+func lowPrivSvc(m *mgr.Mgr, name string) (*mgr.Service, error) {
+	return openSvc(m, name, startStopAccess)
+}
+
 func (ws *windowsService) setEnvironmentVariablesInRegistry() error {
 	if len(ws.EnvVars) == 0 {
 		return nil
 	}
 
 	k, _, err := registry.CreateKey(
-		registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+ws.Name,
+		registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+ws.Config.Name,
 		registry.QUERY_VALUE|registry.SET_VALUE|registry.CREATE_SUB_KEY)
 	if err != nil {
 		return fmt.Errorf("failed creating env var registry key, err = %v", err)
@@ -266,54 +554,395 @@ func (ws *windowsService) setEnvironmentVariablesInRegistry() error {
 	return nil
 }
 
+// Service trigger constants, see
+// https://learn.microsoft.com/en-us/windows/win32/api/winsvc/ns-winsvc-service_trigger
+const (
+	serviceTriggerTypeDomainJoin        = 3
+	serviceTriggerTypeFirewallPortEvent = 4
+	serviceTriggerTypeCustom            = 20
+
+	serviceTriggerActionServiceStart = 1
+	serviceTriggerActionServiceStop  = 2
+
+	serviceTriggerDataTypeString     = 2
+	serviceTriggerDataTypeLevel      = 3
+	serviceTriggerDataTypeKeywordAny = 4
+	serviceTriggerDataTypeKeywordAll = 5
+)
+
+type serviceTriggerSpecificDataItem struct {
+	dataType uint32
+	cbData   uint32
+	data     *byte
+}
+
+type serviceTrigger struct {
+	triggerType    uint32
+	action         uint32
+	triggerSubtype *windows.GUID
+	cDataItems     uint32
+	dataItems      *serviceTriggerSpecificDataItem
+}
+
+type serviceTriggerInfo struct {
+	cTriggers uint32
+	triggers  *serviceTrigger
+	reserved  *byte
+}
+
+// triggerTypes maps the Trigger types to SERVICE_TRIGGER_TYPE values.
+var triggerTypes = map[string]uint32{
+	TriggerCustom:       serviceTriggerTypeCustom,
+	TriggerDomainJoin:   serviceTriggerTypeDomainJoin,
+	TriggerFirewallPort: serviceTriggerTypeFirewallPortEvent,
+}
+
+// defaultTriggerSubtypes are the subtypes of the trigger types that require
+// one, used when Subtype is empty.
+var defaultTriggerSubtypes = map[string]string{
+	TriggerDomainJoin:   TriggerSubtypeDomainJoin,
+	TriggerFirewallPort: TriggerSubtypeFirewallPortOpen,
+}
+
+// multiString encodes strings as a REG_MULTI_SZ, each null terminated and
+// followed by an empty string.
+func multiString(strs []string) []uint16 {
+	var u []uint16
+	for _, s := range strs {
+		u = append(u, utf16.Encode([]rune(s))...)
+		u = append(u, 0)
+	}
+	return append(u, 0)
+}
+
+// parseGUID parses a GUID with or without surrounding braces.
+func parseGUID(s string) (windows.GUID, error) {
+	if !strings.HasPrefix(s, "{") {
+		s = "{" + s + "}"
+	}
+	return windows.GUIDFromString(s)
+}
+
+// validateTriggers checks the type of each trigger is known, and a custom
+// trigger has the Subtype naming its ETW provider.
+func validateTriggers(triggers []Trigger) error {
+	for _, t := range triggers {
+		if _, found := triggerTypes[t.Type]; !found {
+			return fmt.Errorf("unknown trigger type %q", t.Type)
+		}
+		if t.Type == TriggerCustom && t.Subtype == "" {
+			return errors.New("custom trigger without a Subtype, the GUID of its ETW provider")
+		}
+	}
+	return nil
+}
+
+// setTriggers replaces the triggers of the service.
+func setTriggers(s *mgr.Service, triggers []Trigger) error {
+	if err := validateTriggers(triggers); err != nil {
+		return err
+	}
+	st := make([]serviceTrigger, len(triggers))
+	for i, t := range triggers {
+		st[i].triggerType = triggerTypes[t.Type]
+		st[i].action = serviceTriggerActionServiceStart
+		if t.Stop {
+			st[i].action = serviceTriggerActionServiceStop
+		}
+		subtype := t.Subtype
+		if subtype == "" {
+			subtype = defaultTriggerSubtypes[t.Type]
+		}
+		if subtype != "" {
+			guid, err := parseGUID(subtype)
+			if err != nil {
+				return fmt.Errorf("invalid trigger subtype %q: %v", subtype, err)
+			}
+			st[i].triggerSubtype = &guid
+		}
+
+		var items []serviceTriggerSpecificDataItem
+		if t.Level != 0 {
+			level := t.Level
+			items = append(items, serviceTriggerSpecificDataItem{serviceTriggerDataTypeLevel, 1, &level})
+		}
+		for _, kw := range []struct {
+			dataType uint32
+			mask     uint64
+		}{
+			{serviceTriggerDataTypeKeywordAny, t.KeywordsAny},
+			{serviceTriggerDataTypeKeywordAll, t.KeywordsAll},
+		} {
+			if kw.mask == 0 {
+				continue
+			}
+			mask := kw.mask
+			items = append(items, serviceTriggerSpecificDataItem{kw.dataType, 8, (*byte)(unsafe.Pointer(&mask))})
+		}
+		if t.Type == TriggerFirewallPort && len(t.Data) > 0 {
+			// The port event data is a single multi-string item.
+			u := multiString(t.Data)
+			items = append(items, serviceTriggerSpecificDataItem{serviceTriggerDataTypeString, uint32(len(u) * 2), (*byte)(unsafe.Pointer(&u[0]))})
+		} else {
+			for _, d := range t.Data {
+				// String data items are null terminated UTF-16 with the size in bytes.
+				u, err := windows.UTF16FromString(d)
+				if err != nil {
+					return err
+				}
+				items = append(items, serviceTriggerSpecificDataItem{serviceTriggerDataTypeString, uint32(len(u) * 2), (*byte)(unsafe.Pointer(&u[0]))})
+			}
+		}
+		if len(items) > 0 {
+			st[i].cDataItems = uint32(len(items))
+			st[i].dataItems = &items[0]
+		}
+	}
+
+	info := serviceTriggerInfo{cTriggers: uint32(len(st))}
+	if len(st) > 0 {
+		info.triggers = &st[0]
+	}
+	return windows.ChangeServiceConfig2(s.Handle, windows.SERVICE_CONFIG_TRIGGER_INFO, (*byte)(unsafe.Pointer(&info)))
+}
+
+// startStopAccess are the rights granted by the AllowStartStop option, the
+// rights lowPrivSvc opens the service with.
+const startStopAccess = windows.SERVICE_QUERY_CONFIG | windows.SERVICE_QUERY_STATUS | windows.SERVICE_START | windows.SERVICE_STOP
+
+// userName returns the account to run the service as, the NT SERVICE\<name>
+// virtual account with the VirtualAccount option.
+func (ws *windowsService) userName() (string, error) {
+	if !ws.Option.bool(VirtualAccount, false) {
+		return ws.UserName, nil
+	}
+	if ws.UserName != "" {
+		return "", errors.New("UserName can't be set with the VirtualAccount option")
+	}
+	return `NT SERVICE\` + ws.Config.Name, nil
+}
+
+// serviceAccount returns the account name the SCM expects for UserName, and
+// whether it is a built-in or virtual account. Those have no password: the
+// SCM ignores one for the built-in accounts and rejects one for virtual
+// accounts, so the Password option is not used.
+func serviceAccount(userName string) (string, bool) {
+	switch strings.ToLower(userName) {
+	case "":
+		return "", true
+	case "localsystem", `.\localsystem`, `nt authority\system`:
+		return "LocalSystem", true
+	case "localservice", `nt authority\localservice`:
+		return `NT AUTHORITY\LocalService`, true
+	case "networkservice", `nt authority\networkservice`:
+		return `NT AUTHORITY\NetworkService`, true
+	}
+	if strings.HasPrefix(strings.ToLower(userName), `nt service\`) {
+		return userName, true
+	}
+	return userName, false
+}
+
+// accountSID returns the SID of an account name or of a SID string such as
+// "S-1-5-19".
+func accountSID(account string) (*windows.SID, error) {
+	if strings.HasPrefix(account, "S-") {
+		return windows.StringToSid(account)
+	}
+	sid, _, _, err := windows.LookupSID("", account)
+	return sid, err
+}
+
+// allowStartStop adds entries to the DACL of the service granting the
+// accounts the rights to query, start and stop it.
+func allowStartStop(s *mgr.Service, accounts []string) error {
+	sd, err := windows.GetSecurityInfo(s.Handle, windows.SE_SERVICE, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+
+	entries := make([]windows.EXPLICIT_ACCESS, len(accounts))
+	for i, account := range accounts {
+		sid, err := accountSID(account)
+		if err != nil {
+			return fmt.Errorf("account %q: %v", account, err)
+		}
+		entries[i] = windows.EXPLICIT_ACCESS{
+			AccessPermissions: startStopAccess,
+			AccessMode:        windows.GRANT_ACCESS,
+			Inheritance:       windows.NO_INHERITANCE,
+			Trustee: windows.TRUSTEE{
+				TrusteeForm:  windows.TRUSTEE_IS_SID,
+				TrusteeType:  windows.TRUSTEE_IS_UNKNOWN,
+				TrusteeValue: windows.TrusteeValueFromSID(sid),
+			},
+		}
+	}
+	newDACL, err := windows.ACLFromEntries(entries, dacl)
+	if err != nil {
+		return err
+	}
+	return windows.SetSecurityInfo(s.Handle, windows.SE_SERVICE, windows.DACL_SECURITY_INFORMATION, nil, nil, newDACL, nil)
+}
+
+// servicePreferredNodeInfo is SERVICE_PREFERRED_NODE_INFO.
+type servicePreferredNodeInfo struct {
+	preferredNode uint16
+	delete        bool
+}
+
+// setPreferredNode sets the NUMA node the service is started on.
+func setPreferredNode(s *mgr.Service, node uint16) error {
+	info := servicePreferredNodeInfo{preferredNode: node}
+	return windows.ChangeServiceConfig2(s.Handle, windows.SERVICE_CONFIG_PREFERRED_NODE, (*byte)(unsafe.Pointer(&info)))
+}
+
 func (ws *windowsService) Install() error {
+	// Writing the InstallScript installs nothing yet, so the install hooks
+	// and directories are left to whoever runs it.
+	if path := ws.Option.string(InstallScript, ""); path != "" {
+		if err := validateName(ws, ws.Config.Name); err != nil {
+			return err
+		}
+		exepath, err := ws.execPath()
+		if err != nil {
+			return err
+		}
+		return ws.writeInstallScript(path, exepath)
+	}
+	return runInstall(ws.i, ws, ws.Config, ws.install)
+}
+
+func (ws *windowsService) install() error {
 	exepath, err := ws.execPath()
 	if err != nil {
 		return err
 	}
+	triggers, _ := ws.Option[optionTriggers].([]Trigger)
+	if err := validateTriggers(triggers); err != nil {
+		return err
+	}
 
-	m, err := mgr.Connect()
+	m, err := openMgr(installMgrAccess)
 	if err != nil {
-		return err
+		return privilegeError(err)
 	}
 	defer m.Disconnect()
 
 	if err := ws.setEnvironmentVariablesInRegistry(); err != nil {
-		return err
+		return privilegeError(err)
 	}
 
-	s, err := m.OpenService(ws.Name)
+	s, err := openSvc(m, ws.Config.Name, windows.SERVICE_QUERY_STATUS)
 	if err == nil {
 		s.Close()
-		return fmt.Errorf("service %s already exists", ws.Name)
-	}
-	var startType int32
-	switch ws.Option.string(StartType, ServiceStartAutomatic) {
-	case ServiceStartAutomatic:
-		startType = mgr.StartAutomatic
-	case ServiceStartManual:
-		startType = mgr.StartManual
-	case ServiceStartDisabled:
-		startType = mgr.StartDisabled
+		if !markedForDelete(m, ws.Config.Name) {
+			return fmt.Errorf("service %s already exists", ws.Config.Name)
+		}
+		if err := ws.waitDeleted(m); err != nil {
+			return err
+		}
 	}
-
 	serviceType := windows.SERVICE_WIN32_OWN_PROCESS
 	if ws.Option.bool("Interactive", false) {
 		serviceType = serviceType | windows.SERVICE_INTERACTIVE_PROCESS
 	}
 
-	s, err = m.CreateService(ws.Name, exepath, mgr.Config{
+	userName, err := ws.userName()
+	if err != nil {
+		return err
+	}
+	account, builtIn := serviceAccount(userName)
+	password := ws.Option.string("Password", "")
+	if builtIn {
+		password = ""
+	}
+
+	config := mgr.Config{
 		DisplayName:      ws.DisplayName,
-		Description:      ws.Description,
-		StartType:        uint32(startType),
-		ServiceStartName: ws.UserName,
-		Password:         ws.Option.string("Password", ""),
+		Description:      windowsDescription(ws.Description),
+		StartType:        ws.startType(),
+		ServiceStartName: account,
+		Password:         password,
 		Dependencies:     ws.Dependencies,
 		DelayedAutoStart: ws.Option.bool("DelayedAutoStart", false),
 		ServiceType:      uint32(serviceType),
-	}, ws.Arguments...)
+		LoadOrderGroup:   ws.Option.string(LoadOrderGroup, ""),
+	}
+	s, err = m.CreateService(ws.Config.Name, exepath, config, ws.Arguments...)
+	if errors.Is(err, windows.ERROR_SERVICE_MARKED_FOR_DELETE) {
+		// Deleted since the check above, but still open somewhere.
+		if err := ws.waitDeleted(m); err != nil {
+			return err
+		}
+		s, err = m.CreateService(ws.Config.Name, exepath, config, ws.Arguments...)
+	}
+	if errors.Is(err, windows.ERROR_DUPLICATE_SERVICE_NAME) && ws.Option.bool(SuffixDisplayName, false) && displayNameTaken(m, config.DisplayName) {
+		suffixed := config
+		suffixed.DisplayName = fmt.Sprintf("%s (%s)", ws.String(), ws.Config.Name)
+		if s, err = m.CreateService(ws.Config.Name, exepath, suffixed, ws.Arguments...); errors.Is(err, windows.ERROR_DUPLICATE_SERVICE_NAME) {
+			config = suffixed
+		}
+	}
 	if err != nil {
+		if errors.Is(err, windows.ERROR_DUPLICATE_SERVICE_NAME) {
+			// Without a display name the SCM uses the service name.
+			if config.DisplayName == "" {
+				config.DisplayName = ws.Config.Name
+			}
+			return displayNameError(m, config.DisplayName)
+		}
+		return err
+	}
+	defer s.Close()
+	if err := ws.configure(s, triggers); err != nil {
+		// Don't leave a half-configured service blocking the next install.
+		s.Delete()
+		return err
+	}
+	err = settleOn(ws.settleTimeout(), eventLogSettleErrors, func() error {
+		err := eventlog.InstallAsEventCreate(ws.Config.Name, eventlog.Error|eventlog.Warning|eventlog.Info)
+		if err != nil && strings.Contains(err.Error(), "exists") {
+			// Left by an earlier install, or by an attempt above.
+			return nil
+		}
 		return err
+	})
+	if err != nil {
+		s.Delete()
+		return fmt.Errorf("SetupEventLogSource() failed: %s", err)
+	}
+	return nil
+}
+
+// configure applies the settings CreateService can't set to the new
+// service. The service may not be visible to these calls right away, so
+// each is retried until it settles.
+func (ws *windowsService) configure(s *mgr.Service, triggers []Trigger) error {
+	settleTimeout := ws.settleTimeout()
+	if node := ws.Option.int(PreferredNode, -1); node >= 0 {
+		if err := settle(settleTimeout, func() error { return setPreferredNode(s, uint16(node)) }); err != nil {
+			return err
+		}
+	}
+	if timeout := ws.preshutdownTimeout(); timeout > 0 {
+		if err := settle(settleTimeout, func() error { return setPreshutdownTimeout(s, timeout) }); err != nil {
+			return err
+		}
+	}
+	if accounts := ws.Option.strings(AllowStartStop); len(accounts) > 0 {
+		if err := settle(settleTimeout, func() error { return allowStartStop(s, accounts) }); err != nil {
+			return err
+		}
+	}
+	if ws.Option.bool(RequestTag, false) {
+		if err := settle(settleTimeout, func() error { return requestTag(s, ws.Option.string(LoadOrderGroup, "")) }); err != nil {
+			return err
+		}
 	}
 	if onFailure := ws.Option.string(OnFailure, ""); onFailure != "" {
 		var delay = 1 * time.Second
@@ -331,52 +960,433 @@ func (ws *windowsService) Install() error {
 		default:
 			actionType = mgr.ServiceRestart
 		}
-		if err := s.SetRecoveryActions([]mgr.RecoveryAction{
-			{
-				Type:  actionType,
-				Delay: delay,
-			},
-		}, uint32(ws.Option.int(OnFailureResetPeriod, 10))); err != nil {
+		if err := settle(settleTimeout, func() error {
+			return s.SetRecoveryActions([]mgr.RecoveryAction{
+				{
+					Type:  actionType,
+					Delay: delay,
+				},
+			}, uint32(ws.Option.int(OnFailureResetPeriod, 10)))
+		}); err != nil {
 			return err
 		}
+		if ws.Option.bool(OnFailureForNonCrash, false) {
+			if err := settle(settleTimeout, func() error { return s.SetRecoveryActionsOnNonCrashFailures(true) }); err != nil {
+				return err
+			}
+		}
 	}
-	defer s.Close()
-	err = eventlog.InstallAsEventCreate(ws.Name, eventlog.Error|eventlog.Warning|eventlog.Info)
-	if err != nil {
-		if !strings.Contains(err.Error(), "exists") {
-			s.Delete()
-			return fmt.Errorf("SetupEventLogSource() failed: %s", err)
+	if len(triggers) > 0 {
+		if err := settle(settleTimeout, func() error { return setTriggers(s, triggers) }); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (ws *windowsService) Uninstall() error {
-	m, err := mgr.Connect()
+// installScriptUnsupported are the options an install script can not set.
+var installScriptUnsupported = []string{optionTriggers, AllowStartStop, RequestTag, SuffixDisplayName}
+
+// writeInstallScript writes the install steps to a batch file at path
+// instead of running them, for an installer to apply later.
+func (ws *windowsService) writeInstallScript(path, exepath string) error {
+	lines, err := ws.installScript(exepath)
 	if err != nil {
 		return err
 	}
-	defer m.Disconnect()
-	s, err := m.OpenService(ws.Name)
+	return os.WriteFile(path, []byte(strings.Join(lines, "\r\n")+"\r\n"), 0600)
+}
+
+// installScript returns the sc.exe and reg.exe commands that install the
+// service the way install does.
+func (ws *windowsService) installScript(exepath string) ([]string, error) {
+	for _, name := range installScriptUnsupported {
+		if _, ok := ws.Option[name]; ok {
+			return nil, fmt.Errorf("%w: option %s in an install script", ErrNotSupported, name)
+		}
+	}
+	if strings.ContainsAny(ws.Description, "\r\n") {
+		return nil, fmt.Errorf("%w: multi-line description in an install script", ErrNotSupported)
+	}
+	userName, err := ws.userName()
+	if err != nil {
+		return nil, err
+	}
+	account, builtIn := serviceAccount(userName)
+
+	name := cmdQuote(ws.Config.Name)
+	create := "sc.exe create " + name + " binPath= " + cmdQuote(binaryPathName(exepath, ws.Arguments)) + " type= own"
+	if ws.Option.bool("Interactive", false) {
+		create += " type= interact"
+	}
+	switch start := ws.startType(); {
+	case start == mgr.StartManual:
+		create += " start= demand"
+	case start == mgr.StartDisabled:
+		create += " start= disabled"
+	case ws.Option.bool("DelayedAutoStart", false):
+		create += " start= delayed-auto"
+	default:
+		create += " start= auto"
+	}
+	if ws.DisplayName != "" {
+		create += " DisplayName= " + cmdQuote(ws.DisplayName)
+	}
+	if account != "" {
+		create += " obj= " + cmdQuote(account)
+		if password := ws.Option.string("Password", ""); password != "" && !builtIn {
+			create += " password= " + cmdQuote(password)
+		}
+	}
+	if len(ws.Dependencies) > 0 {
+		create += " depend= " + cmdQuote(strings.Join(ws.Dependencies, "/"))
+	}
+	if group := ws.Option.string(LoadOrderGroup, ""); group != "" {
+		create += " group= " + cmdQuote(group)
+	}
+	lines := []string{"@echo off", create + " || exit /b 1"}
+
+	if ws.Description != "" {
+		lines = append(lines, "sc.exe description "+name+" "+cmdQuote(ws.Description))
+	}
+	key := cmdQuote(`HKLM\SYSTEM\CurrentControlSet\Services\` + ws.Config.Name)
+	if len(ws.EnvVars) > 0 {
+		envStrings := make([]string, 0, len(ws.EnvVars))
+		for k, v := range ws.EnvVars {
+			envStrings = append(envStrings, k+"="+v)
+		}
+		sort.Strings(envStrings)
+		lines = append(lines, "reg.exe add "+key+" /v Environment /t REG_MULTI_SZ /d "+cmdQuote(strings.Join(envStrings, `\0`))+" /f")
+	}
+	if node := ws.Option.int(PreferredNode, -1); node >= 0 {
+		lines = append(lines, fmt.Sprintf("sc.exe preferrednode %s %d", name, node))
+	}
+	if timeout := ws.preshutdownTimeout(); timeout > 0 {
+		lines = append(lines, fmt.Sprintf("reg.exe add %s /v PreshutdownTimeout /t REG_DWORD /d %d /f", key, timeout/time.Millisecond))
+	}
+	if onFailure := ws.Option.string(OnFailure, ""); onFailure != "" {
+		var delay = 1 * time.Second
+		if d, err := time.ParseDuration(ws.Option.string(OnFailureDelayDuration, "1s")); err == nil {
+			delay = d
+		}
+		var actions string
+		switch onFailure {
+		case OnFailureReboot:
+			actions = fmt.Sprintf("reboot/%d", delay/time.Millisecond)
+		case OnFailureNoAction:
+			actions = `""`
+		default:
+			actions = fmt.Sprintf("restart/%d", delay/time.Millisecond)
+		}
+		lines = append(lines, fmt.Sprintf("sc.exe failure %s reset= %d actions= %s",
+			name, ws.Option.int(OnFailureResetPeriod, 10), actions))
+		if ws.Option.bool(OnFailureForNonCrash, false) {
+			lines = append(lines, "sc.exe failureflag "+name+" 1")
+		}
+	}
+	source := cmdQuote(`HKLM\SYSTEM\CurrentControlSet\Services\EventLog\Application\` + ws.Config.Name)
+	lines = append(lines,
+		"reg.exe add "+source+" /v CustomSource /t REG_DWORD /d 1 /f",
+		"reg.exe add "+source+` /v EventMessageFile /t REG_EXPAND_SZ /d "%%SystemRoot%%\System32\EventCreate.exe" /f`,
+		fmt.Sprintf("reg.exe add %s /v TypesSupported /t REG_DWORD /d %d /f", source, eventlog.Error|eventlog.Warning|eventlog.Info),
+	)
+	if !isASCII(strings.Join(lines, "")) {
+		// cmd reads a batch file in the OEM code page, switch it to UTF-8
+		// for the lines with a localized name or description.
+		lines = append([]string{lines[0], "chcp 65001 >nul"}, lines[1:]...)
+	}
+	return lines, nil
+}
+
+// isASCII reports whether s only has ASCII characters.
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// cmdQuote quotes s as a single argument on a batch file command line.
+func cmdQuote(s string) string {
+	q := syscall.EscapeArg(s)
+	if !strings.HasPrefix(q, `"`) {
+		// Quote anyway, so cmd does not act on & | < > ^ in s. Trailing
+		// backslashes would escape the closing quote and are doubled.
+		trailing := len(q) - len(strings.TrimRight(q, `\`))
+		q = `"` + q + strings.Repeat(`\`, trailing) + `"`
+	}
+	return strings.Replace(q, "%", "%%", -1)
+}
+
+// displayNameError returns ErrDisplayNameInUse naming the service that
+// already uses the display name, when it can be found.
+func displayNameError(m *mgr.Mgr, displayName string) error {
+	if name, err := serviceKeyName(m, displayName); err == nil {
+		return fmt.Errorf("%w: %q is the display name of service %s", ErrDisplayNameInUse, displayName, name)
+	}
+	return fmt.Errorf("%w: %q", ErrDisplayNameInUse, displayName)
+}
+
+// displayNameTaken reports if another service has the display name, rather
+// than it colliding with a service name.
+func displayNameTaken(m *mgr.Mgr, displayName string) bool {
+	if displayName == "" {
+		return false
+	}
+	_, err := serviceKeyName(m, displayName)
+	return err == nil
+}
+
+// serviceKeyName returns the name of the service with the display name.
+func serviceKeyName(m *mgr.Mgr, displayName string) (string, error) {
+	p, err := windows.UTF16PtrFromString(displayName)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]uint16, 257)
+	size := uint32(len(buf))
+	r1, _, e1 := procGetServiceKeyNameW.Call(
+		uintptr(m.Handle), uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)))
+	if r1 == 0 {
+		return "", e1
+	}
+	return windows.UTF16ToString(buf), nil
+}
+
+// windowsDescription returns the description with CRLF line endings, which is
+// how the SCM and the Services console expect multi-line descriptions. An
+// indirect string such as "@%SystemRoot%\app.dll,-101" is localized by the SCM.
+func windowsDescription(d string) string {
+	return strings.Replace(strings.Replace(d, "\r\n", "\n", -1), "\n", "\r\n", -1)
+}
+
+// startType returns the mgr start type for the StartType option.
+func (ws *windowsService) startType() uint32 {
+	switch ws.Option.string(StartType, ServiceStartAutomatic) {
+	case ServiceStartManual:
+		return mgr.StartManual
+	case ServiceStartDisabled:
+		return mgr.StartDisabled
+	default:
+		return mgr.StartAutomatic
+	}
+}
+
+func (ws *windowsService) installedStartType() (string, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return "", err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return "", ErrNotInstalled
+		}
+		return "", err
+	}
+	defer s.Close()
+
+	c, err := s.Config()
+	if err != nil {
+		return "", err
+	}
+	switch c.StartType {
+	case mgr.StartAutomatic:
+		return ServiceStartAutomatic, nil
+	case mgr.StartDisabled:
+		return ServiceStartDisabled, nil
+	default:
+		return ServiceStartManual, nil
+	}
+}
+
+// installedMatches compares the binary path, arguments, account and start
+// type of the installed service with the Config.
+func (ws *windowsService) installedMatches() (bool, error) {
+	exepath, err := ws.execPath()
+	if err != nil {
+		return false, err
+	}
+
+	m, err := lowPrivMgr()
+	if err != nil {
+		return false, err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return false, ErrNotInstalled
+		}
+		return false, err
+	}
+	defer s.Close()
+
+	c, err := s.Config()
+	if err != nil {
+		return false, err
+	}
+
+	binaryPath := binaryPathName(exepath, ws.Arguments)
+	userName, err := ws.userName()
+	if err != nil {
+		return false, err
+	}
+	account, _ := serviceAccount(userName)
+	if account == "" {
+		account = "LocalSystem"
+	}
+
+	return c.BinaryPathName == binaryPath &&
+		strings.EqualFold(c.ServiceStartName, account) &&
+		c.Description == windowsDescription(ws.Description) &&
+		c.StartType == ws.startType() &&
+		c.DelayedAutoStart == ws.Option.bool("DelayedAutoStart", false), nil
+}
+
+// binaryPathName returns the BinaryPathName of a service running exepath
+// with args, built the same way as mgr.CreateService.
+func binaryPathName(exepath string, args []string) string {
+	binaryPath := syscall.EscapeArg(exepath)
+	for _, arg := range args {
+		binaryPath += " " + syscall.EscapeArg(arg)
+	}
+	return binaryPath
+}
+
+// updateExecutable points the installed service at the executable and
+// arguments of the Config, keeping the rest of its configuration.
+func (ws *windowsService) updateExecutable() error {
+	exepath, err := ws.execPath()
+	if err != nil {
+		return err
+	}
+	binaryPath := binaryPathName(exepath, ws.Arguments)
+	return ws.updateConfig(func(c *mgr.Config) {
+		c.BinaryPathName = binaryPath
+	})
+}
+
+// setStartType changes the start type of the installed service.
+func (ws *windowsService) setStartType(startType uint32) error {
+	return ws.updateConfig(func(c *mgr.Config) {
+		c.StartType = startType
+	})
+}
+
+// SetDelayedAutoStart changes the delayed automatic start of the installed
+// service in place.
+func (ws *windowsService) SetDelayedAutoStart(delayed bool) error {
+	return ws.updateConfig(func(c *mgr.Config) {
+		c.DelayedAutoStart = delayed
+	})
+}
+
+// updateConfig changes the configuration of the installed service with
+// update, keeping the settings it doesn't change.
+func (ws *windowsService) updateConfig(update func(c *mgr.Config)) error {
+	m, err := openMgr(windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return privilegeError(err)
+	}
+	defer m.Disconnect()
+
+	s, err := openSvc(m, ws.Config.Name, changeConfigAccess)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return ErrNotInstalled
+		}
+		return privilegeError(err)
+	}
+	defer s.Close()
+
+	c, err := s.Config()
+	if err != nil {
+		return err
+	}
+	update(&c)
+	return s.UpdateConfig(c)
+}
+
+// Enable sets the start type from the StartType option, or automatic
+// if the option is disabled.
+func (ws *windowsService) Enable() error {
+	if ws.Option.string(StartType, ServiceStartAutomatic) == ServiceStartManual {
+		return ws.setStartType(mgr.StartManual)
+	}
+	return ws.setStartType(mgr.StartAutomatic)
+}
+
+func (ws *windowsService) Disable() error {
+	return ws.setStartType(mgr.StartDisabled)
+}
+
+func (ws *windowsService) Uninstall() error {
+	return runUninstall(ws.i, ws, ws.Config, func() error { return ws.uninstall(false) })
+}
+
+// forceUninstall uninstalls the service even if it or its dependents do
+// not stop, killing it instead. The SCM deletes the service once the last
+// handle to it is closed.
+func (ws *windowsService) forceUninstall() error {
+	return runUninstall(ws.i, ws, ws.Config, func() error { return ws.uninstall(true) })
+}
+
+func (ws *windowsService) uninstall(force bool) error {
+	m, err := openMgr(windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return privilegeError(err)
+	}
+	defer m.Disconnect()
+	s, err := openSvc(m, ws.Config.Name, uninstallAccess)
 	if err != nil {
 		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
 			// not installed
 			return nil
 		}
-		return fmt.Errorf("error open service %s", ws.Name)
+		if errors.Is(err, os.ErrPermission) {
+			return privilegeError(err)
+		}
+		return fmt.Errorf("error open service %s", ws.Config.Name)
 	}
 	defer s.Close()
 
-	if err := ws.Stop(); err != nil {
+	dependents, err := s.ListDependentServices(svc.AnyActivity)
+	if err != nil {
 		return err
 	}
+	if len(dependents) > 0 {
+		if !ws.Option.bool(StopDependents, false) && !force {
+			return fmt.Errorf("%w: %s", ErrHasDependents, strings.Join(dependents, ", "))
+		}
+		for _, name := range dependents {
+			if err := stopService(m, name, ws.stopTimeout()); err != nil && !force {
+				return fmt.Errorf("stop dependent service %s: %w", name, err)
+			}
+		}
+	}
+
+	if err := ws.Stop(); err != nil {
+		if !force {
+			return err
+		}
+		if err := ws.Kill(); err != nil && !errors.Is(err, ErrNotRunning) {
+			return err
+		}
+	}
 
 	err = s.Delete()
 	if err != nil {
 		return err
 	}
 
-	err = eventlog.Remove(ws.Name)
+	err = eventlog.Remove(ws.Config.Name)
 	if err != nil && !errors.Is(err, syscall.ERROR_FILE_NOT_FOUND) {
 		return fmt.Errorf("RemoveEventLogSource() failed: %s", err)
 	}
@@ -384,6 +1394,30 @@ func (ws *windowsService) Uninstall() error {
 	return nil
 }
 
+// markedForDelete reports if the named service is deleted but still open
+// somewhere, such as in the Services console. Changing nothing in its
+// configuration fails for such a service.
+func markedForDelete(m *mgr.Mgr, name string) bool {
+	s, err := openSvc(m, name, windows.SERVICE_CHANGE_CONFIG)
+	if err != nil {
+		return false
+	}
+	defer s.Close()
+	err = windows.ChangeServiceConfig(s.Handle, windows.SERVICE_NO_CHANGE, windows.SERVICE_NO_CHANGE,
+		windows.SERVICE_NO_CHANGE, nil, nil, nil, nil, nil, nil, nil)
+	return errors.Is(err, windows.ERROR_SERVICE_MARKED_FOR_DELETE)
+}
+
+// waitDeleted waits for the service marked for deletion to go away, which
+// happens once the last handle to it is closed.
+func (ws *windowsService) waitDeleted(m *mgr.Mgr) error {
+	if err := ws.uninstallWait(m); err != nil {
+		return fmt.Errorf("%w: service %s, close the programs that have it open, such as the Services console, or reboot",
+			windows.ERROR_SERVICE_MARKED_FOR_DELETE, ws.Config.Name)
+	}
+	return nil
+}
+
 func (ws *windowsService) uninstallWait(m *mgr.Mgr) error {
 	// wait until the service is deleted
 	timeDuration := time.Millisecond * 200
@@ -393,28 +1427,41 @@ func (ws *windowsService) uninstallWait(m *mgr.Mgr) error {
 	for {
 		select {
 		case <-tick.C:
-			svc, err := m.OpenService(ws.Name)
+			svc, err := openSvc(m, ws.Config.Name, windows.SERVICE_QUERY_STATUS)
 			if err != nil {
 				if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
 					return nil
 				}
-				return fmt.Errorf("error open service %s", ws.Name)
+				return fmt.Errorf("error open service %s", ws.Config.Name)
 			}
 			svc.Close()
 		case <-timeout:
-			return fmt.Errorf("delete service %s timeout", ws.Name)
+			return fmt.Errorf("delete service %s timeout", ws.Config.Name)
 		}
 	}
 }
 
 func (ws *windowsService) Run() error {
 	ws.setError(nil)
-	if !interactive {
+	if !Interactive() {
+		if ws.Option.bool(LoadUserEnvironment, false) {
+			unload, err := ws.loadUserEnvironment()
+			if err != nil {
+				return fmt.Errorf("load user environment: %w", err)
+			}
+			defer unload()
+		}
+		restore, err := captureOutput(ws, ws.Option)
+		if err != nil {
+			return err
+		}
+		defer restore()
+
 		// Return error messages from start and stop routines
 		// that get executed in the Execute method.
 		// Guarded with a mutex as it may run a different thread
 		// (callback from windows).
-		runErr := svc.Run(ws.Name, ws)
+		runErr := svc.Run(ws.Config.Name, ws)
 		startStopErr := ws.getError()
 		if startStopErr != nil {
 			return startStopErr
@@ -424,18 +1471,102 @@ func (ws *windowsService) Run() error {
 		}
 		return nil
 	}
+	notifyState(ws.i, ws, StateStartPending)
 	err := ws.i.Start(ws)
 	if err != nil {
+		notifyState(ws.i, ws, StateStopped)
 		return err
 	}
+	notifyState(ws.i, ws, StateRunning)
+	stopWatch := watch(ws.i, ws, ws.Option)
 
 	sigChan := make(chan os.Signal)
 
 	signal.Notify(sigChan, os.Interrupt)
 
 	<-sigChan
+	stopWatch()
 
-	return ws.i.Stop(ws)
+	notifyState(ws.i, ws, StateStopPending)
+	err = stopInteractive(ws.Option, func() error { return ws.i.Stop(ws) })
+	notifyState(ws.i, ws, StateStopped)
+	return err
+}
+
+// loadUserEnvironment loads the profile of the service account with
+// LoadUserProfile, as a logon does, then replaces the environment of the
+// process with the one built from it, and sets the EnvVars again so they
+// still take precedence. LoadUserProfile needs the backup and restore
+// privileges of an administrator or LocalSystem, without them the
+// environment is built without loading the profile. The returned func
+// unloads the profile.
+func (ws *windowsService) loadUserEnvironment() (func(), error) {
+	var token windows.Token
+	err := windows.OpenProcessToken(windows.CurrentProcess(),
+		windows.TOKEN_QUERY|windows.TOKEN_DUPLICATE|windows.TOKEN_IMPERSONATE, &token)
+	if err != nil {
+		return nil, err
+	}
+	unloadProfile, err := loadUserProfile(token)
+	if err != nil {
+		ConsoleLogger.Warningf("Cannot load the user profile, using the environment of the account: %v", err)
+		unloadProfile = func() {}
+	}
+	unload := func() {
+		unloadProfile()
+		token.Close()
+	}
+	env, err := token.Environ(false)
+	if err == nil {
+		err = setEnviron(env, ws.EnvVars)
+	}
+	if err != nil {
+		unload()
+		return nil, err
+	}
+	return unload, nil
+}
+
+// loadUserProfile loads the profile of the user of token, and returns the
+// func unloading it.
+func loadUserProfile(token windows.Token) (func(), error) {
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	account, _, _, err := user.User.Sid.LookupAccount("")
+	if err != nil {
+		return nil, err
+	}
+	userName, err := windows.UTF16PtrFromString(account)
+	if err != nil {
+		return nil, err
+	}
+	info := profileInfo{flags: piNoUI, userName: userName}
+	info.size = uint32(unsafe.Sizeof(info))
+	if r1, _, e1 := procLoadUserProfileW.Call(uintptr(token), uintptr(unsafe.Pointer(&info))); r1 == 0 {
+		return nil, fmt.Errorf("load profile of %s: %w", account, e1)
+	}
+	return func() { procUnloadUserProfile.Call(uintptr(token), uintptr(info.profile)) }, nil
+}
+
+// setEnviron sets the variables of env, as "KEY=value", then the ones of
+// vars.
+func setEnviron(env []string, vars map[string]string) error {
+	for _, kv := range env {
+		// Skip the per drive directories such as "=C:=C:\".
+		if i := strings.Index(kv, "="); i > 0 {
+			if err := os.Setenv(kv[:i], kv[i+1:]); err != nil {
+				return err
+			}
+		}
+	}
+	for k, v := range vars {
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (ws *windowsService) Status() (Status, error) {
@@ -445,7 +1576,50 @@ func (ws *windowsService) Status() (Status, error) {
 	}
 	defer m.Disconnect()
 
-	s, err := lowPrivSvc(m, ws.Name)
+	return queryStatus(m, ws.Config.Name)
+}
+
+// pending reports if the SCM is starting, stopping, pausing or continuing
+// the service, which Status reports as running or stopped.
+func (ws *windowsService) pending() (bool, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return false, err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return false, ErrNotInstalled
+		}
+		return false, err
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return false, err
+	}
+	return pendingState(status.State), nil
+}
+
+// pendingState reports if state is one of the pending states.
+func pendingState(state svc.State) bool {
+	switch state {
+	case svc.StartPending, svc.StopPending, svc.PausePending, svc.ContinuePending:
+		return true
+	}
+	return false
+}
+
+func (ws *windowsService) Installed() (bool, error) {
+	return installed(ws)
+}
+
+// queryStatus returns the status of the named service using an open manager.
+func queryStatus(m *mgr.Mgr, name string) (Status, error) {
+	s, err := lowPrivSvc(m, name)
 	if err != nil {
 		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
 			return StatusUnknown, ErrNotInstalled
@@ -459,7 +1633,14 @@ func (ws *windowsService) Status() (Status, error) {
 		return StatusUnknown, err
 	}
 
-	switch status.State {
+	return stateStatus(status)
+}
+
+// stateStatus maps the state of a Windows service to a Status. A stopped
+// service with a non-zero exit code has failed, other than one that was
+// never started since it was installed.
+func stateStatus(status svc.Status) (Status, error) {
+	switch state := status.State; state {
 	case svc.StartPending:
 		fallthrough
 	case svc.Running:
@@ -471,53 +1652,469 @@ func (ws *windowsService) Status() (Status, error) {
 	case svc.ContinuePending:
 		fallthrough
 	case svc.StopPending:
-		fallthrough
+		return StatusStopped, nil
 	case svc.Stopped:
+		if status.Win32ExitCode != 0 && status.Win32ExitCode != uint32(windows.ERROR_SERVICE_NEVER_STARTED) {
+			return StatusFailed, nil
+		}
 		return StatusStopped, nil
 	default:
-		return StatusUnknown, fmt.Errorf("unknown status %v", status)
+		return StatusUnknown, fmt.Errorf("unknown status %v", state)
 	}
 }
 
-func (ws *windowsService) Start() error {
-	status, _ := ws.Status()
-	if status == StatusRunning {
-		return nil
+// validateName checks the name has no backslash and fits in the 256
+// characters of a service name.
+func (ws *windowsService) validateName() error {
+	if strings.Contains(ws.Config.Name, `\`) {
+		return fmt.Errorf("%w %q: contains a backslash", ErrInvalidName, ws.Config.Name)
+	}
+	if len(utf16.Encode([]rune(ws.Config.Name))) > 256 {
+		return fmt.Errorf("%w %q: longer than the 256 characters of a Windows service name", ErrInvalidName, ws.Config.Name)
+	}
+	return nil
+}
+
+// location returns the registry key holding the configuration of the service.
+func (ws *windowsService) location() (string, error) {
+	return `HKLM\SYSTEM\CurrentControlSet\Services\` + ws.Config.Name, nil
+}
+
+func (ws *windowsService) StatusText() (string, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return "", err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return "", ErrNotInstalled
+		}
+		return "", err
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return "", err
+	}
+	return statusText(status), nil
+}
+
+// statusText describes the progress of a pending service, or why a stopped
+// service failed.
+func statusText(status svc.Status) string {
+	switch status.State {
+	case svc.StartPending, svc.StopPending, svc.PausePending, svc.ContinuePending:
+		if status.CheckPoint > 0 {
+			return fmt.Sprintf("checkpoint %d", status.CheckPoint)
+		}
+	case svc.Stopped:
+		if status.Win32ExitCode == uint32(windows.ERROR_SERVICE_SPECIFIC_ERROR) {
+			return fmt.Sprintf("service specific exit code %d", status.ServiceSpecificExitCode)
+		}
+		if status.Win32ExitCode != 0 {
+			return syscall.Errno(status.Win32ExitCode).Error()
+		}
+	}
+	return ""
+}
+
+// requestTag has the SCM assign the service a tag unique within its load
+// order group. mgr.CreateService does not ask for one.
+func requestTag(s *mgr.Service, group string) error {
+	groupPtr, err := syscall.UTF16PtrFromString(group)
+	if err != nil {
+		return err
+	}
+	var tag uint32
+	return windows.ChangeServiceConfig(s.Handle, windows.SERVICE_NO_CHANGE, windows.SERVICE_NO_CHANGE,
+		windows.SERVICE_NO_CHANGE, nil, groupPtr, &tag, nil, nil, nil, nil)
+}
+
+func (ws *windowsService) tag() (uint32, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return 0, err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return 0, ErrNotInstalled
+		}
+		return 0, err
+	}
+	defer s.Close()
+
+	c, err := s.Config()
+	if err != nil {
+		return 0, err
+	}
+	return c.TagId, nil
+}
+
+func (ws *windowsService) accepts() (Accepted, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return 0, err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return 0, ErrNotInstalled
+		}
+		return 0, err
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return 0, err
+	}
+	if status.State == svc.Stopped {
+		return 0, ErrNotRunning
+	}
+
+	var accepted Accepted
+	if status.Accepts&svc.AcceptStop != 0 {
+		accepted |= AcceptStop
+	}
+	if status.Accepts&svc.AcceptPauseAndContinue != 0 {
+		accepted |= AcceptPauseContinue
 	}
+	if status.Accepts&svc.AcceptShutdown != 0 {
+		accepted |= AcceptShutdown
+	}
+	if status.Accepts&svc.AcceptPreShutdown != 0 {
+		accepted |= AcceptPreShutdown
+	}
+	return accepted, nil
+}
+
+var (
+	modpsapi                 = windows.NewLazySystemDLL("psapi.dll")
+	procGetProcessMemoryInfo = modpsapi.NewProc("GetProcessMemoryInfo")
+
+	modadvapi32            = windows.NewLazySystemDLL("advapi32.dll")
+	procGetServiceKeyNameW = modadvapi32.NewProc("GetServiceKeyNameW")
+
+	modshell32          = windows.NewLazySystemDLL("shell32.dll")
+	procShellExecuteExW = modshell32.NewProc("ShellExecuteExW")
+
+	moduserenv            = windows.NewLazySystemDLL("userenv.dll")
+	procLoadUserProfileW  = moduserenv.NewProc("LoadUserProfileW")
+	procUnloadUserProfile = moduserenv.NewProc("UnloadUserProfile")
+)
+
+// profileInfo is PROFILEINFOW.
+type profileInfo struct {
+	size        uint32
+	flags       uint32
+	userName    *uint16
+	profilePath *uint16
+	defaultPath *uint16
+	serverName  *uint16
+	policyPath  *uint16
+	profile     windows.Handle
+}
+
+const piNoUI = 0x1
+
+// shellExecuteInfo is SHELLEXECUTEINFOW.
+type shellExecuteInfo struct {
+	cbSize        uint32
+	fMask         uint32
+	hwnd          windows.Handle
+	verb          *uint16
+	file          *uint16
+	parameters    *uint16
+	directory     *uint16
+	show          int32
+	instApp       windows.Handle
+	idList        uintptr
+	class         *uint16
+	keyClass      windows.Handle
+	hotKey        uint32
+	iconOrMonitor windows.Handle
+	process       windows.Handle
+}
+
+const seeMaskNoCloseProcess = 0x40
+
+// relaunchElevated runs the executable with the arguments of the process
+// with the runas verb, which shows the UAC prompt, and waits for it.
+func relaunchElevated() (bool, error) {
+	exepath, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return false, err
+	}
+	info := shellExecuteInfo{
+		fMask:      seeMaskNoCloseProcess,
+		verb:       syscall.StringToUTF16Ptr("runas"),
+		file:       syscall.StringToUTF16Ptr(exepath),
+		parameters: syscall.StringToUTF16Ptr(windows.ComposeCommandLine(os.Args[1:])),
+		directory:  syscall.StringToUTF16Ptr(dir),
+		show:       windows.SW_SHOWNORMAL,
+	}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+	if r1, _, e1 := procShellExecuteExW.Call(uintptr(unsafe.Pointer(&info))); r1 == 0 {
+		// Includes the user declining the UAC prompt.
+		return false, e1
+	}
+	defer windows.CloseHandle(info.process)
+
+	if _, err := windows.WaitForSingleObject(info.process, windows.INFINITE); err != nil {
+		return true, err
+	}
+	var code uint32
+	if err := windows.GetExitCodeProcess(info.process, &code); err != nil {
+		return true, err
+	}
+	if code != 0 {
+		return true, fmt.Errorf("elevated process exited with code %d", code)
+	}
+	return true, nil
+}
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS.
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+func filetimeDuration(ft windows.Filetime) time.Duration {
+	// Filetime durations are in 100-nanosecond intervals.
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
 
+// InstalledCommand splits the BinaryPathName of the installed service.
+func (ws *windowsService) InstalledCommand() (string, []string, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return "", nil, err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return "", nil, ErrNotInstalled
+		}
+		return "", nil, err
+	}
+	defer s.Close()
+
+	c, err := s.Config()
+	if err != nil {
+		return "", nil, err
+	}
+	words, err := windows.DecomposeCommandLine(c.BinaryPathName)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(words) == 0 {
+		return "", nil, errors.New("the service has no command line")
+	}
+	return words[0], words[1:], nil
+}
+
+// installedConfig reads the configuration of the installed service.
+func (ws *windowsService) installedConfig() (*Config, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return nil, err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return nil, ErrNotInstalled
+		}
+		return nil, err
+	}
+	defer s.Close()
+
+	mc, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+	words, err := windows.DecomposeCommandLine(mc.BinaryPathName)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Config{
+		Name:         ws.Config.Name,
+		DisplayName:  mc.DisplayName,
+		Description:  mc.Description,
+		Dependencies: mc.Dependencies,
+		Option:       KeyValue{},
+	}
+	if len(words) > 0 {
+		c.Executable, c.Arguments = words[0], words[1:]
+	}
+	if !strings.EqualFold(mc.ServiceStartName, "LocalSystem") {
+		c.UserName = mc.ServiceStartName
+	}
+	switch mc.StartType {
+	case mgr.StartManual:
+		c.Option[StartType] = ServiceStartManual
+	case mgr.StartDisabled:
+		c.Option[StartType] = ServiceStartDisabled
+	default:
+		c.Option[StartType] = ServiceStartAutomatic
+	}
+	if mc.DelayedAutoStart {
+		c.Option["DelayedAutoStart"] = true
+	}
+	if mc.LoadOrderGroup != "" {
+		c.Option[LoadOrderGroup] = mc.LoadOrderGroup
+	}
+	return c, nil
+}
+
+// Dependents lists the installed services that depend on the service, in
+// the order they should be stopped.
+func (ws *windowsService) Dependents() ([]string, error) {
+	m, err := openMgr(windows.SC_MANAGER_CONNECT)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Disconnect()
+
+	s, err := openSvc(m, ws.Config.Name, windows.SERVICE_ENUMERATE_DEPENDENTS)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return nil, ErrNotInstalled
+		}
+		return nil, err
+	}
+	defer s.Close()
+
+	return s.ListDependentServices(svc.AnyActivity)
+}
+
+// Kill terminates the service process, the SCM then reports the service as
+// stopped and applies the OnFailure action.
+func (ws *windowsService) Kill() error {
 	m, err := lowPrivMgr()
 	if err != nil {
 		return err
 	}
 	defer m.Disconnect()
 
-	s, err := lowPrivSvc(m, ws.Name)
+	s, err := lowPrivSvc(m, ws.Config.Name)
 	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return ErrNotInstalled
+		}
 		return err
 	}
 	defer s.Close()
-	return s.Start()
+
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.State == svc.Stopped || status.ProcessId == 0 {
+		return ErrNotRunning
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_TERMINATE, false, status.ProcessId)
+	if err != nil {
+		return privilegeError(err)
+	}
+	defer windows.CloseHandle(h)
+	return windows.TerminateProcess(h, 1)
 }
 
-func (ws *windowsService) Stop() error {
-	status, _ := ws.Status()
-	if status != StatusRunning {
-		return nil
+func (ws *windowsService) Usage() (ResourceUsage, error) {
+	m, err := lowPrivMgr()
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	defer m.Disconnect()
+
+	s, err := lowPrivSvc(m, ws.Config.Name)
+	if err != nil {
+		if errno, ok := err.(syscall.Errno); ok && errno == errnoServiceDoesNotExist {
+			return ResourceUsage{}, ErrNotInstalled
+		}
+		return ResourceUsage{}, err
+	}
+	defer s.Close()
+
+	status, err := s.Query()
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	if status.State == svc.Stopped || status.ProcessId == 0 {
+		return ResourceUsage{}, ErrNotRunning
+	}
+
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION|windows.PROCESS_VM_READ, false, status.ProcessId)
+	if err != nil {
+		return ResourceUsage{}, err
+	}
+	defer windows.CloseHandle(h)
+
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &creation, &exit, &kernel, &user); err != nil {
+		return ResourceUsage{}, err
+	}
+
+	var counters processMemoryCounters
+	counters.cb = uint32(unsafe.Sizeof(counters))
+	r1, _, e1 := procGetProcessMemoryInfo.Call(uintptr(h), uintptr(unsafe.Pointer(&counters)), uintptr(counters.cb))
+	if r1 == 0 {
+		return ResourceUsage{}, e1
 	}
 
+	return ResourceUsage{
+		PID:       int(status.ProcessId),
+		RSS:       uint64(counters.WorkingSetSize),
+		CPUTime:   filetimeDuration(kernel) + filetimeDuration(user),
+		StartTime: time.Unix(0, creation.Nanoseconds()),
+	}, nil
+}
+
+func (ws *windowsService) Start() error {
 	m, err := lowPrivMgr()
 	if err != nil {
 		return err
 	}
 	defer m.Disconnect()
 
-	s, err := lowPrivSvc(m, ws.Name)
+	return startService(m, ws.Config.Name)
+}
+
+func (ws *windowsService) Stop() error {
+	m, err := lowPrivMgr()
 	if err != nil {
 		return err
 	}
-	defer s.Close()
+	defer m.Disconnect()
 
-	return ws.stopWait(s)
+	return stopService(m, ws.Config.Name, ws.stopTimeout())
 }
 
 func (ws *windowsService) Restart() error {
@@ -527,13 +2124,46 @@ func (ws *windowsService) Restart() error {
 	}
 	defer m.Disconnect()
 
-	s, err := lowPrivSvc(m, ws.Name)
+	return restartService(m, ws.Config.Name, ws.stopTimeout())
+}
+
+func startService(m *mgr.Mgr, name string) error {
+	status, _ := queryStatus(m, name)
+	if status == StatusRunning {
+		return nil
+	}
+
+	s, err := lowPrivSvc(m, name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.Start()
+}
+
+func stopService(m *mgr.Mgr, name string, timeout time.Duration) error {
+	status, _ := queryStatus(m, name)
+	if status != StatusRunning {
+		return nil
+	}
+
+	s, err := lowPrivSvc(m, name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return stopWait(s, timeout)
+}
+
+func restartService(m *mgr.Mgr, name string, timeout time.Duration) error {
+	s, err := lowPrivSvc(m, name)
 	if err != nil {
 		return err
 	}
 	defer s.Close()
 
-	err = ws.stopWait(s)
+	err = stopWait(s, timeout)
 	if err != nil {
 		return err
 	}
@@ -541,39 +2171,61 @@ func (ws *windowsService) Restart() error {
 	return s.Start()
 }
 
-func (ws *windowsService) stopWait(s *mgr.Service) error {
-	st, _ := ws.Status()
-	if st == StatusStopped {
+// stopPollMax is the longest stopWait waits between status queries.
+const stopPollMax = time.Second
+
+// stopWait stops the service and waits for it to stop, returning
+// ErrStopTimeout if it is still running after timeout.
+func stopWait(s *mgr.Service, timeout time.Duration) error {
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if st, _ := stateStatus(status); st == StatusStopped || st == StatusFailed {
 		return nil
 	}
 
 	// First stop the service. Then wait for the service to
 	// actually stop before starting it.
-	status, err := s.Control(svc.Stop)
+	status, err = s.Control(svc.Stop)
 	if err != nil {
 		return err
 	}
 
-	timeDuration := time.Millisecond * 50
-
-	timeout := time.After(getStopTimeout() + (timeDuration * 2))
-	tick := time.NewTicker(timeDuration)
-	defer tick.Stop()
+	// Poll quickly at first and back off, so a service that takes minutes
+	// to stop isn't queried every 50ms.
+	interval := time.Millisecond * 50
+	deadline := time.NewTimer(timeout + (interval * 2))
+	defer deadline.Stop()
 
 	for status.State != svc.Stopped {
 		select {
-		case <-tick.C:
+		case <-time.After(interval):
 			status, err = s.Query()
 			if err != nil {
 				return err
 			}
-		case <-timeout:
-			return fmt.Errorf("stop service %s timeout", ws.Name)
+			if interval *= 2; interval > stopPollMax {
+				interval = stopPollMax
+			}
+		case <-deadline.C:
+			return fmt.Errorf("stop service %s: %w", s.Name, ErrStopTimeout)
 		}
 	}
 	return nil
 }
 
+// inContainer checks for the ContainerType value Windows sets in containers.
+func inContainer() bool {
+	key, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Control`, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	_, _, err = key.GetIntegerValue("ContainerType")
+	return err == nil
+}
+
 // getStopTimeout fetches the time before windows will kill the service.
 func getStopTimeout() time.Duration {
 	// For default and paths see https://support.microsoft.com/en-us/kb/146092
@@ -594,15 +2246,50 @@ func getStopTimeout() time.Duration {
 }
 
 func (ws *windowsService) Logger(errs chan<- error) (Logger, error) {
-	if interactive {
-		return ConsoleLogger, nil
+	if Interactive() {
+		return ws.Config.interactiveLogger(), nil
 	}
 	return ws.SystemLogger(errs)
 }
+
+// SystemLogger returns a logger writing to the event log, or to stderr if the
+// event log can't be opened.
 func (ws *windowsService) SystemLogger(errs chan<- error) (Logger, error) {
-	el, err := eventlog.Open(ws.Name)
+	if ws.Option.string(optionLogger, "") == optionLoggerNull {
+		return NullLogger, nil
+	}
+	if ws.Option.string(optionLogFile, "") != "" {
+		return ws.fileLogger(errs)
+	}
+	// Right after Install the event source may not be visible yet, retry
+	// for a moment before giving up on the event log.
+	var el *eventlog.Log
+	var err error
+	for delay := 100 * time.Millisecond; ; delay *= 2 {
+		if el, err = eventlog.Open(ws.Config.Name); err == nil || delay > time.Second {
+			break
+		}
+		time.Sleep(delay)
+	}
 	if err != nil {
-		return nil, err
+		// Losing the event log must not stop the service from running.
+		ConsoleLogger.Warningf("Cannot open the event log, logging to stderr: %v", err)
+		return ConsoleLogger, nil
 	}
-	return WindowsLogger{el, errs}, nil
+	return WindowsLogger{el, errs, nil}, nil
+}
+
+// watchReload does nothing, on Windows Execute restarts the program on the
+// paramchange control.
+func watchReload(i Interface, s Service, kv KeyValue) func() {
+	return func() {}
+}
+
+// directoryRoots is empty, the RuntimeDirectory, StateDirectory and
+// LogsDirectory options are not supported on Windows.
+var directoryRoots = map[string]string{}
+
+// chown does nothing, directory owners are not supported on Windows.
+func chown(path, userName, groupName string) error {
+	return nil
 }
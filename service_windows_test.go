@@ -5,10 +5,347 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
 )
 
 func TestTimeout(t *testing.T) {
 	stopSpan := getStopTimeout()
 	t.Log("Max Stop Duration", stopSpan)
 }
+
+func TestWindowsDescription(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"one line", "one line"},
+		{"first\nsecond", "first\r\nsecond"},
+		{"first\r\nsecond\n", "first\r\nsecond\r\n"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := windowsDescription(tt.description); got != tt.want {
+			t.Errorf("windowsDescription(%q) = %q, want %q", tt.description, got, tt.want)
+		}
+	}
+}
+
+func TestServiceAccount(t *testing.T) {
+	tests := []struct {
+		userName string
+		want     string
+		builtIn  bool
+	}{
+		{"", "", true},
+		{"LocalSystem", "LocalSystem", true},
+		{`NT AUTHORITY\System`, "LocalSystem", true},
+		{"LocalService", `NT AUTHORITY\LocalService`, true},
+		{`nt authority\networkservice`, `NT AUTHORITY\NetworkService`, true},
+		{`NT SERVICE\myapp`, `NT SERVICE\myapp`, true},
+		{`DOMAIN\svc-myapp`, `DOMAIN\svc-myapp`, false},
+	}
+	for _, tt := range tests {
+		got, builtIn := serviceAccount(tt.userName)
+		if got != tt.want || builtIn != tt.builtIn {
+			t.Errorf("serviceAccount(%q) = %q, %v, want %q, %v", tt.userName, got, builtIn, tt.want, tt.builtIn)
+		}
+	}
+}
+
+func TestVirtualAccount(t *testing.T) {
+	ws := &windowsService{Config: &Config{Name: "myapp", Option: KeyValue{VirtualAccount: true}}}
+	if got, err := ws.userName(); err != nil || got != `NT SERVICE\myapp` {
+		t.Errorf("userName() = %q, %v, want %q", got, err, `NT SERVICE\myapp`)
+	}
+	ws.UserName = "other"
+	if _, err := ws.userName(); err == nil {
+		t.Error("userName() with UserName and VirtualAccount returned no error")
+	}
+}
+
+func TestMultiString(t *testing.T) {
+	got := multiString([]string{"80", "TCP"})
+	want := []uint16{'8', '0', 0, 'T', 'C', 'P', 0, 0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("multiString() = %v, want %v", got, want)
+	}
+}
+
+func TestCmdQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"myapp", `"myapp"`},
+		{"a&b", `"a&b"`},
+		{`C:\dir\`, `"C:\dir\\"`},
+		{"100%", `"100%%"`},
+		{`"C:\Program Files\app.exe" -v`, `"\"C:\Program Files\app.exe\" -v"`},
+	}
+	for _, tt := range tests {
+		if got := cmdQuote(tt.in); got != tt.want {
+			t.Errorf("cmdQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInstallScript(t *testing.T) {
+	ws := &windowsService{Config: &Config{
+		Name:        "myapp",
+		DisplayName: "My App",
+		Arguments:   []string{"-v"},
+		EnvVars:     map[string]string{"B": "2", "A": "1"},
+		Option:      KeyValue{OnFailure: OnFailureRestart},
+	}}
+	lines, err := ws.installScript(`C:\app\myapp.exe`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`sc.exe create "myapp" binPath= "C:\app\myapp.exe -v" type= own start= auto DisplayName= "My App" || exit /b 1`,
+		`reg.exe add "HKLM\SYSTEM\CurrentControlSet\Services\myapp" /v Environment /t REG_MULTI_SZ /d "A=1\0B=2" /f`,
+		`sc.exe failure "myapp" reset= 10 actions= restart/1000`,
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("installScript() = %q, missing %q", lines, want)
+		}
+	}
+
+	ws.Option[AllowStartStop] = []string{"Users"}
+	if _, err := ws.installScript(`C:\app\myapp.exe`); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("installScript() with AllowStartStop error = %v, want %v", err, ErrNotSupported)
+	}
+}
+
+// installHooks records the install hooks that run.
+type installHooks struct {
+	restartCounter
+	ran []string
+}
+
+func (p *installHooks) PreInstall(s Service) error {
+	p.ran = append(p.ran, "PreInstall")
+	return nil
+}
+
+func (p *installHooks) PreUninstall(s Service) error { return nil }
+
+func (p *installHooks) PostInstall(s Service) error {
+	p.ran = append(p.ran, "PostInstall")
+	return nil
+}
+
+func (p *installHooks) PostUninstall(s Service) error { return nil }
+
+func TestInstallScriptSkipsHooks(t *testing.T) {
+	dir := t.TempDir()
+	p := &installHooks{}
+	ws := &windowsService{i: p, Config: &Config{
+		Name:        "myapp",
+		Executable:  `C:\app\myapp.exe`,
+		Directories: []Directory{{Path: filepath.Join(dir, "data")}},
+		Option:      KeyValue{InstallScript: filepath.Join(dir, "install.cmd")},
+	}}
+	if err := ws.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "install.cmd")); err != nil {
+		t.Errorf("Install() with InstallScript wrote no script: %v", err)
+	}
+	if len(p.ran) != 0 {
+		t.Errorf("Install() with InstallScript ran %q, want no hooks", p.ran)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "data")); err == nil {
+		t.Error("Install() with InstallScript made the service directories")
+	}
+}
+
+func TestInstallScriptUnicode(t *testing.T) {
+	ws := &windowsService{Config: &Config{
+		Name:        "überwachung",
+		DisplayName: "Überwachungsdienst 監視",
+		Description: "Überwacht den Speicher — ストレージ",
+		Option:      KeyValue{},
+	}}
+	lines, err := ws.installScript(`C:\Programme\Überwachung\app.exe`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) < 3 || lines[1] != "chcp 65001 >nul" {
+		t.Fatalf("installScript() = %q, want chcp 65001 as the second line", lines)
+	}
+	for _, want := range []string{
+		`sc.exe create "überwachung" binPath= "C:\Programme\Überwachung\app.exe" type= own start= auto DisplayName= "Überwachungsdienst 監視" || exit /b 1`,
+		`sc.exe description "überwachung" "Überwacht den Speicher — ストレージ"`,
+	} {
+		found := false
+		for _, line := range lines {
+			found = found || line == want
+		}
+		if !found {
+			t.Errorf("installScript() = %q, missing %q", lines, want)
+		}
+	}
+
+	ws.Config = &Config{Name: "myapp", Option: KeyValue{}}
+	if lines, err = ws.installScript(`C:\app\myapp.exe`); err != nil || lines[1] == "chcp 65001 >nul" {
+		t.Errorf("installScript() of an ASCII service = %q, %v, want no chcp", lines, err)
+	}
+}
+
+func TestOpenSvcNUL(t *testing.T) {
+	if _, err := openSvc(&mgr.Mgr{}, "my\x00app", windows.SERVICE_QUERY_STATUS); err == nil {
+		t.Error("openSvc() of a name with a NUL returned no error")
+	}
+}
+
+func TestSettle(t *testing.T) {
+	calls := 0
+	err := settle(time.Second, func() error {
+		calls++
+		if calls < 3 {
+			return windows.ERROR_SERVICE_DOES_NOT_EXIST
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("settle() = %v after %d calls, want nil after 3", err, calls)
+	}
+
+	calls = 0
+	err = settle(time.Second, func() error {
+		calls++
+		return windows.ERROR_ACCESS_DENIED
+	})
+	if err != windows.ERROR_ACCESS_DENIED || calls != 1 {
+		t.Errorf("settle() = %v after %d calls, want access denied after 1", err, calls)
+	}
+
+	calls = 0
+	err = settle(time.Second, func() error {
+		calls++
+		return windows.ERROR_FILE_NOT_FOUND
+	})
+	if err != windows.ERROR_FILE_NOT_FOUND || calls != 1 {
+		t.Errorf("settle() = %v after %d calls, want file not found after 1", err, calls)
+	}
+
+	calls = 0
+	err = settle(200*time.Millisecond, func() error {
+		calls++
+		return fmt.Errorf("set triggers: %w", windows.ERROR_SERVICE_DATABASE_LOCKED)
+	})
+	if !errors.Is(err, windows.ERROR_SERVICE_DATABASE_LOCKED) || calls < 2 {
+		t.Errorf("settle() = %v after %d calls, want database locked after retrying", err, calls)
+	}
+
+	calls = 0
+	err = settleOn(time.Second, eventLogSettleErrors, func() error {
+		calls++
+		if calls < 2 {
+			return windows.ERROR_ACCESS_DENIED
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("settleOn() = %v after %d calls, want nil after 2", err, calls)
+	}
+}
+
+func TestStateStatus(t *testing.T) {
+	tests := []struct {
+		status svc.Status
+		want   Status
+	}{
+		{svc.Status{State: svc.Running}, StatusRunning},
+		{svc.Status{State: svc.Stopped}, StatusStopped},
+		{svc.Status{State: svc.Stopped, Win32ExitCode: uint32(windows.ERROR_SERVICE_NEVER_STARTED)}, StatusStopped},
+		{svc.Status{State: svc.Stopped, Win32ExitCode: uint32(windows.ERROR_SERVICE_SPECIFIC_ERROR)}, StatusFailed},
+		{svc.Status{State: svc.StopPending, Win32ExitCode: uint32(windows.ERROR_SERVICE_SPECIFIC_ERROR)}, StatusStopped},
+		{svc.Status{State: svc.Paused, Win32ExitCode: 1}, StatusStopped},
+	}
+	for _, tt := range tests {
+		if got, err := stateStatus(tt.status); err != nil || got != tt.want {
+			t.Errorf("stateStatus(%+v) = %v, %v, want %v", tt.status, got, err, tt.want)
+		}
+	}
+}
+
+func TestPendingState(t *testing.T) {
+	for state, want := range map[svc.State]bool{
+		svc.StartPending: true,
+		svc.StopPending:  true,
+		svc.Running:      false,
+		svc.Stopped:      false,
+	} {
+		if got := pendingState(state); got != want {
+			t.Errorf("pendingState(%v) = %v, want %v", state, got, want)
+		}
+	}
+}
+
+func TestStatusText(t *testing.T) {
+	tests := []struct {
+		status svc.Status
+		want   string
+	}{
+		{svc.Status{State: svc.Running}, ""},
+		{svc.Status{State: svc.StartPending, CheckPoint: 3}, "checkpoint 3"},
+		{svc.Status{State: svc.Stopped}, ""},
+		{svc.Status{State: svc.Stopped, Win32ExitCode: uint32(windows.ERROR_SERVICE_SPECIFIC_ERROR), ServiceSpecificExitCode: 42}, "service specific exit code 42"},
+	}
+	for _, tt := range tests {
+		if got := statusText(tt.status); got != tt.want {
+			t.Errorf("statusText(%+v) = %q, want %q", tt.status, got, tt.want)
+		}
+	}
+}
+
+func TestSetEnviron(t *testing.T) {
+	t.Setenv("SERVICE_TEST_PROFILE", "")
+	t.Setenv("SERVICE_TEST_OVERRIDE", "")
+	env := []string{"=C:=C:\\app", "SERVICE_TEST_PROFILE=profile", "SERVICE_TEST_OVERRIDE=profile"}
+	if err := setEnviron(env, map[string]string{"SERVICE_TEST_OVERRIDE": "config"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("SERVICE_TEST_PROFILE"); got != "profile" {
+		t.Errorf("SERVICE_TEST_PROFILE = %q, want profile", got)
+	}
+	if got := os.Getenv("SERVICE_TEST_OVERRIDE"); got != "config" {
+		t.Errorf("SERVICE_TEST_OVERRIDE = %q, want the EnvVars value config", got)
+	}
+}
+
+func TestValidateTriggers(t *testing.T) {
+	tests := []struct {
+		name    string
+		trigger Trigger
+		valid   bool
+	}{
+		{"custom", Trigger{Type: TriggerCustom, Subtype: "{1ce20aba-9851-4421-9430-1ddeb766e809}"}, true},
+		{"custom without subtype", Trigger{Type: TriggerCustom}, false},
+		{"default subtype", Trigger{Type: TriggerDomainJoin}, true},
+		{"unknown type", Trigger{Type: "usb"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateTriggers([]Trigger{tt.trigger}); (err == nil) != tt.valid {
+				t.Errorf("validateTriggers() error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Poll intervals of the Wait funcs, they back off from the first to the
+// last.
+const (
+	waitPollMin = 50 * time.Millisecond
+	waitPollMax = time.Second
+)
+
+// poll calls done until it reports true or fails, backing off from
+// waitPollMin to waitPollMax. It returns false if timeout passes first.
+func poll(timeout time.Duration, done func() (bool, error)) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	interval := waitPollMin
+	for {
+		if ok, err := done(); ok || err != nil {
+			return ok, err
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false, nil
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+		time.Sleep(interval)
+		if interval *= 2; interval > waitPollMax {
+			interval = waitPollMax
+		}
+	}
+}
+
+// pendingReporter is implemented by services whose Status reports one the
+// service manager is still starting as running, or still stopping as
+// stopped. pending reports if a start or stop is in progress.
+type pendingReporter interface {
+	pending() (bool, error)
+}
+
+// settled reports if no start or stop of s is in progress.
+func settled(s Service) (bool, error) {
+	if p, ok := s.(pendingReporter); ok {
+		pending, err := p.pending()
+		return !pending, err
+	}
+	return true, nil
+}
+
+// WaitStopped polls the status of s until it is stopped or failed, whoever
+// stopped it, such as an operator using the service manager. Unlike Stop it
+// doesn't stop the service itself. It returns ErrStopTimeout if the service
+// is still running after timeout, and the error of Status if it fails, such
+// as ErrNotInstalled.
+func WaitStopped(s Service, timeout time.Duration) error {
+	stopped, err := poll(timeout, func() (bool, error) {
+		status, err := s.Status()
+		if err != nil || status != StatusStopped && status != StatusFailed {
+			return false, err
+		}
+		return settled(s)
+	})
+	if err == nil && !stopped {
+		return ErrStopTimeout
+	}
+	return err
+}
+
+// WaitHealthy polls Healthy of h until it returns nil, such as after Start
+// or Restart, so a deployment knows the service is ready and not only
+// running. It returns ErrHealthTimeout if h is still not healthy after
+// timeout, and ErrNotInstalled or ErrNotRunning if the service is not
+// running at all.
+func WaitHealthy(s Service, h HealthChecker, timeout time.Duration) error {
+	var unhealthy error
+	healthy, err := poll(timeout, func() (bool, error) {
+		if unhealthy = h.Healthy(s); unhealthy == nil {
+			return true, nil
+		}
+		status, err := s.Status()
+		if err != nil {
+			return false, err
+		}
+		if status == StatusStopped || status == StatusFailed {
+			return false, fmt.Errorf("%w: %v", ErrNotRunning, unhealthy)
+		}
+		return false, nil
+	})
+	if err == nil && !healthy {
+		return fmt.Errorf("%w: %v", ErrHealthTimeout, unhealthy)
+	}
+	return err
+}
+
+// RestartHealthy restarts s and waits until h is healthy, see WaitHealthy.
+func RestartHealthy(s Service, h HealthChecker, timeout time.Duration) error {
+	if err := s.Restart(); err != nil {
+		return err
+	}
+	return WaitHealthy(s, h, timeout)
+}
+
+// WaitRunning polls the status of s until it is running, such as after
+// Start returned while the service manager is still starting it. It returns
+// ErrStartTimeout if the service is not running after timeout, an error
+// matching ErrNotRunning if it failed, and the error of Status if it fails.
+func WaitRunning(s Service, timeout time.Duration) error {
+	running, err := poll(timeout, func() (bool, error) {
+		status, err := s.Status()
+		if err != nil {
+			return false, err
+		}
+		switch status {
+		case StatusRunning:
+			return settled(s)
+		case StatusFailed:
+			return false, fmt.Errorf("%w: the service failed", ErrNotRunning)
+		}
+		return false, nil
+	})
+	if err == nil && !running {
+		return ErrStartTimeout
+	}
+	return err
+}
+
+// InstallAndStart installs s, starts it and waits until it is running, see
+// WaitRunning. A service that fails to start is left installed, so its logs
+// and status can be inspected.
+func InstallAndStart(s Service, timeout time.Duration) error {
+	if err := s.Install(); err != nil {
+		return err
+	}
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("installed %s but failed to start it: %w", s, err)
+	}
+	return WaitRunning(s, timeout)
+}
+
+// StopAndUninstall stops s if it is running, waits until it is stopped, see
+// WaitStopped, and uninstalls it. It does nothing if s is not installed.
+// Unlike ForceUninstall, a service that doesn't stop in time is not killed
+// and stays installed, the error is ErrStopTimeout.
+func StopAndUninstall(s Service, timeout time.Duration) error {
+	installed, err := s.Installed()
+	if err != nil || !installed {
+		return err
+	}
+	status, err := s.Status()
+	if err != nil {
+		return err
+	}
+	if status == StatusRunning {
+		if err := s.Stop(); err != nil {
+			return err
+		}
+		if err := WaitStopped(s, timeout); err != nil {
+			return err
+		}
+	}
+	if err := s.Uninstall(); err != nil && !errors.Is(err, ErrNotInstalled) {
+		return err
+	}
+	return nil
+}
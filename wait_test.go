@@ -0,0 +1,165 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// statusSequence is a Service whose Status returns the statuses in order,
+// then the last one.
+type statusSequence struct {
+	Service
+	statuses []Status
+}
+
+func (s *statusSequence) Status() (Status, error) {
+	status := s.statuses[0]
+	if len(s.statuses) > 1 {
+		s.statuses = s.statuses[1:]
+	}
+	return status, nil
+}
+
+func TestWaitStopped(t *testing.T) {
+	s := &statusSequence{statuses: []Status{StatusRunning, StatusRunning, StatusStopped}}
+	if err := WaitStopped(s, time.Second); err != nil {
+		t.Errorf("WaitStopped() error = %v, want nil", err)
+	}
+
+	s = &statusSequence{statuses: []Status{StatusRunning}}
+	start := time.Now()
+	if err := WaitStopped(s, 100*time.Millisecond); err != ErrStopTimeout {
+		t.Errorf("WaitStopped() error = %v, want %v", err, ErrStopTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitStopped() took %v, want about the timeout", elapsed)
+	}
+}
+
+// healthAfter is a HealthChecker that is healthy after a number of calls.
+type healthAfter struct {
+	restartCounter
+	calls int
+}
+
+func (h *healthAfter) Healthy(s Service) error {
+	if h.calls--; h.calls > 0 {
+		return errors.New("not ready")
+	}
+	return nil
+}
+
+func TestWaitHealthy(t *testing.T) {
+	s := &statusSequence{statuses: []Status{StatusRunning}}
+	if err := WaitHealthy(s, &healthAfter{calls: 3}, time.Second); err != nil {
+		t.Errorf("WaitHealthy() error = %v, want nil", err)
+	}
+	if err := WaitHealthy(s, &healthAfter{calls: 1000}, 100*time.Millisecond); !errors.Is(err, ErrHealthTimeout) {
+		t.Errorf("WaitHealthy() error = %v, want %v", err, ErrHealthTimeout)
+	}
+
+	s = &statusSequence{statuses: []Status{StatusStopped}}
+	if err := WaitHealthy(s, &healthAfter{calls: 1000}, time.Second); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("WaitHealthy() of stopped service error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+func TestWaitRunning(t *testing.T) {
+	s := &statusSequence{statuses: []Status{StatusStopped, StatusRunning}}
+	if err := WaitRunning(s, time.Second); err != nil {
+		t.Errorf("WaitRunning() error = %v, want nil", err)
+	}
+	s = &statusSequence{statuses: []Status{StatusStopped}}
+	if err := WaitRunning(s, 100*time.Millisecond); err != ErrStartTimeout {
+		t.Errorf("WaitRunning() error = %v, want %v", err, ErrStartTimeout)
+	}
+	s = &statusSequence{statuses: []Status{StatusStopped, StatusFailed}}
+	if err := WaitRunning(s, time.Second); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("WaitRunning() of failed service error = %v, want %v", err, ErrNotRunning)
+	}
+}
+
+// startingService is a Service running with a start pending a number of
+// calls of pending, as a Windows service in StartPending.
+type startingService struct {
+	Service
+	pendingCalls int
+}
+
+func (s *startingService) Status() (Status, error) { return StatusRunning, nil }
+func (s *startingService) pending() (bool, error) {
+	s.pendingCalls--
+	return s.pendingCalls >= 0, nil
+}
+
+func TestWaitRunningPending(t *testing.T) {
+	s := &startingService{pendingCalls: 2}
+	if err := WaitRunning(s, time.Second); err != nil || s.pendingCalls != -1 {
+		t.Errorf("WaitRunning() = %v with %d pending calls left, want nil after the start", err, s.pendingCalls)
+	}
+	s = &startingService{pendingCalls: 1000}
+	if err := WaitRunning(s, 100*time.Millisecond); err != ErrStartTimeout {
+		t.Errorf("WaitRunning() of a service still starting error = %v, want %v", err, ErrStartTimeout)
+	}
+}
+
+// pendingStop is a stopped Service with a stop pending a number of calls of
+// pending, as a Windows service in StopPending.
+type pendingStop struct {
+	Service
+	pendingCalls int
+}
+
+func (s *pendingStop) Status() (Status, error) { return StatusStopped, nil }
+func (s *pendingStop) pending() (bool, error) {
+	s.pendingCalls--
+	return s.pendingCalls >= 0, nil
+}
+
+func TestWaitStoppedPending(t *testing.T) {
+	s := &pendingStop{pendingCalls: 2}
+	if err := WaitStopped(s, time.Second); err != nil || s.pendingCalls != -1 {
+		t.Errorf("WaitStopped() = %v with %d pending calls left, want nil after the stop", err, s.pendingCalls)
+	}
+}
+
+// stoppingService is an installed, running Service that stops when asked.
+type stoppingService struct {
+	Service
+	stopped, uninstalled bool
+}
+
+func (s *stoppingService) Status() (Status, error) {
+	switch {
+	case s.uninstalled:
+		return StatusUnknown, ErrNotInstalled
+	case s.stopped:
+		return StatusStopped, nil
+	}
+	return StatusRunning, nil
+}
+func (s *stoppingService) Installed() (bool, error) { return !s.uninstalled, nil }
+func (s *stoppingService) Stop() error              { s.stopped = true; return nil }
+func (s *stoppingService) Uninstall() error         { s.uninstalled = true; return nil }
+
+func TestStopAndUninstall(t *testing.T) {
+	s := &stoppingService{}
+	if err := StopAndUninstall(s, time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !s.stopped || !s.uninstalled {
+		t.Errorf("StopAndUninstall() stopped %v, uninstalled %v, want both", s.stopped, s.uninstalled)
+	}
+	if err := StopAndUninstall(s, time.Second); err != nil {
+		t.Errorf("StopAndUninstall() of uninstalled service error = %v, want nil", err)
+	}
+
+	if err := StopAndUninstall(&stuckService{}, time.Second); err != ErrStopTimeout {
+		t.Errorf("StopAndUninstall() of stuck service error = %v, want %v", err, ErrStopTimeout)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// watchInterval is how often the Watch option paths are polled.
+var watchInterval = time.Second
+
+// fileState is what is compared to tell if a watched path changed.
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statPaths(paths []string) []fileState {
+	states := make([]fileState, len(paths))
+	for i, p := range paths {
+		if fi, err := os.Stat(p); err == nil {
+			states[i] = fileState{true, fi.Size(), fi.ModTime()}
+		}
+	}
+	return states
+}
+
+// restartInProcess calls Stop then Start, keeping the process running. A
+// failure to stop is logged, a failure to start is returned.
+func restartInProcess(i Interface, s Service) error {
+	notifyState(i, s, StateStopPending)
+	if err := i.Stop(s); err != nil {
+		ConsoleLogger.Errorf("Restart failed to stop: %v", err)
+	}
+	notifyState(i, s, StateStopped)
+	notifyState(i, s, StateStartPending)
+	if err := i.Start(s); err != nil {
+		return err
+	}
+	notifyState(i, s, StateRunning)
+	return nil
+}
+
+// reloadSignal returns the ReloadSignal option, or HUP when RestartOnReload
+// is set without it.
+func reloadSignal(kv KeyValue) string {
+	if kv.bool(optionRestartOnReload, false) {
+		return kv.string(optionReloadSignal, "HUP")
+	}
+	return kv.string(optionReloadSignal, "")
+}
+
+// watch restarts the program, calling Stop then Start, when a path of the
+// Watch option changes while it runs interactively, and on the reload signal
+// with the RestartOnReload option. The returned function stops watching and
+// waits for a restart in progress to finish.
+func watch(i Interface, s Service, kv KeyValue) func() {
+	stopReload := watchReload(i, s, kv)
+	paths := kv.strings(optionWatch)
+	if !Interactive() || len(paths) == 0 {
+		return stopReload
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		last := statPaths(paths)
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			current := statPaths(paths)
+			changed := false
+			for n := range current {
+				changed = changed || current[n] != last[n]
+			}
+			last = current
+			if !changed {
+				continue
+			}
+
+			ConsoleLogger.Info("Watched file changed, restarting")
+			if err := restartInProcess(i, s); err != nil {
+				ConsoleLogger.Errorf("Restart failed to start: %v", err)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+		stopReload()
+	}
+}
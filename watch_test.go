@@ -0,0 +1,56 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type restartCounter struct {
+	starts, stops int32
+}
+
+func (p *restartCounter) Start(s Service) error {
+	atomic.AddInt32(&p.starts, 1)
+	return nil
+}
+
+func (p *restartCounter) Stop(s Service) error {
+	atomic.AddInt32(&p.stops, 1)
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	if !Interactive() {
+		t.Skip("watch only runs interactively")
+	}
+	defer func(d time.Duration) { watchInterval = d }(watchInterval)
+	watchInterval = 10 * time.Millisecond
+
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &restartCounter{}
+	stopWatch := watch(p, nil, KeyValue{optionWatch: []string{path}})
+
+	time.Sleep(5 * watchInterval)
+	if err := os.WriteFile(path, []byte("ab"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&p.starts) == 0 && time.Now().Before(deadline) {
+		time.Sleep(watchInterval)
+	}
+	stopWatch()
+
+	if starts, stops := atomic.LoadInt32(&p.starts), atomic.LoadInt32(&p.stops); starts != 1 || stops != 1 {
+		t.Errorf("got %d starts and %d stops, want 1 each", starts, stops)
+	}
+}